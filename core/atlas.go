@@ -22,13 +22,20 @@ type Biome struct {
 	ImpassLite  bool
 }
 
-// Generate creates a new tile chosen according to the Biome parameters.
+// Generate creates a new tile chosen according to the Biome parameters, using
+// the global Dice.
 func (b Biome) Generate(o Offset) *Tile {
+	return b.GenerateWith(globalDice, o)
+}
+
+// GenerateWith creates a new tile chosen according to the Biome parameters,
+// using the given Dice.
+func (b Biome) GenerateWith(d Dice, o Offset) *Tile {
 	t := NewTile(o)
-	if RandChance(b.PassChance) {
-		t.Face = b.PassTiles[RandIntn(len(b.PassTiles))]
+	if d.Chance(b.PassChance) {
+		t.Face = b.PassTiles[d.Intn(len(b.PassTiles))]
 	} else {
-		t.Face = b.ImpassTiles[RandIntn(len(b.ImpassTiles))]
+		t.Face = b.ImpassTiles[d.Intn(len(b.ImpassTiles))]
 		t.Pass = false
 		t.Lite = b.ImpassLite
 	}
@@ -53,8 +60,16 @@ func (l BiomeList) Swap(i, j int) {
 	l[i], l[j] = l[j], l[i]
 }
 
-// NewMapGen creates a new MapGenFloat using the Biome data.
+// NewMapGen creates a new MapGenFloat using the Biome data. The Tiles are
+// chosen with the global Dice.
 func (l BiomeList) NewMapGen() MapGenFloat {
+	return l.NewMapGenWith(globalDice)
+}
+
+// NewMapGenWith creates a new MapGenFloat using the Biome data. The Tiles are
+// chosen with the given Dice as the MapGenFloat is applied, so applying it to
+// the same Heightmap in the same order gives the same map.
+func (l BiomeList) NewMapGenWith(d Dice) MapGenFloat {
 	lcopy := make(BiomeList, len(l))
 	copy(lcopy, l)
 	sort.Sort(sort.Reverse(lcopy))
@@ -66,10 +81,101 @@ func (l BiomeList) NewMapGen() MapGenFloat {
 				maxbiome = biome
 			}
 		}
-		return maxbiome.Generate(o)
+		return maxbiome.GenerateWith(d, o)
+	}
+}
+
+// BiomeTable selects a Biome from the temperature and moisture of a location,
+// in the style of a Whittaker diagram. Temperatures and Moistures give the
+// upper bound of each band, in ascending order, with values past the last bound
+// falling into the last band. Empty bounds give a single band, so a BiomeTable
+// with no Moistures varies by temperature alone. Biomes[i][j] is the Biome for
+// the ith temperature band and the jth moisture band. Locations below the
+// SeaLevel are instead chosen from the Sea BiomeList by height, as with
+// BiomeList.
+type BiomeTable struct {
+	Temperatures []float64
+	Moistures    []float64
+	Biomes       [][]Biome
+	SeaLevel     float64
+	Sea          BiomeList
+}
+
+// band finds the index of the band containing a value. With no bounds, every
+// value falls into a single band, so the index is always 0.
+func band(bounds []float64, value float64) int {
+	if len(bounds) == 0 {
+		return 0
+	}
+	for i, bound := range bounds {
+		if value < bound {
+			return i
+		}
+	}
+	return len(bounds) - 1
+}
+
+// Select returns the Biome for the given temperature and moisture.
+func (t BiomeTable) Select(temperature, moisture float64) Biome {
+	return t.Biomes[band(t.Temperatures, temperature)][band(t.Moistures, moisture)]
+}
+
+// NewMapGen creates a new MapGenFloat using the BiomeTable, as with
+// NewMapGenWith, choosing the Tiles with the global Dice.
+func (t BiomeTable) NewMapGen(temp *TemperatureMap, moist *MoistureMap) MapGenFloat {
+	return t.NewMapGenWith(globalDice, temp, moist)
+}
+
+// NewMapGenWith creates a new MapGenFloat using the BiomeTable. The given
+// TemperatureMap and MoistureMap must have been generated from the Heightmap
+// which the MapGenFloat is applied to. The mean temperature is used, so the
+// result does not depend on the Season. The Tiles are chosen with the given
+// Dice, as with BiomeList.NewMapGenWith.
+func (t BiomeTable) NewMapGenWith(d Dice, temp *TemperatureMap, moist *MoistureMap) MapGenFloat {
+	sea := t.Sea.NewMapGenWith(d)
+	return func(o Offset, height float64) *Tile {
+		if height < t.SeaLevel && len(t.Sea) > 0 {
+			return sea(o, height)
+		}
+		x, y := temp.height.cell(o)
+		return t.Select(temp.Mean(x, y), moist.Read(x, y)).GenerateWith(d, o)
 	}
 }
 
+// WhittakerBiomes is a BiomeTable with the land biomes of a simplified
+// Whittaker diagram, using the default TemperatureMap and MoistureMap scales.
+// From cold to hot, the temperature bands give ice and tundra, boreal steppe
+// and taiga, temperate grassland and forest, and desert, savanna and jungle.
+// The wettest band of each is bog or swamp. The Sea is left empty, so every
+// location is treated as land unless a Sea is given.
+var WhittakerBiomes = BiomeTable{
+	Temperatures: []float64{-5, 5, 20, math.Inf(1)},
+	Moistures:    []float64{.25, .5, .75, math.Inf(1)},
+	Biomes: [][]Biome{
+		{
+			{PassTiles: []Glyph{{'.', ColorLightWhite}}, PassChance: 1},
+			{PassTiles: []Glyph{{',', ColorWhite}}, ImpassTiles: []Glyph{{'*', ColorLightWhite}}, PassChance: .98},
+			{PassTiles: []Glyph{{',', ColorWhite}}, ImpassTiles: []Glyph{{'*', ColorLightWhite}}, PassChance: .95},
+			{PassTiles: []Glyph{{'"', ColorWhite}}, ImpassTiles: []Glyph{{'%', ColorWhite}}, PassChance: .9},
+		}, {
+			{PassTiles: []Glyph{{'"', ColorYellow}}, PassChance: 1},
+			{PassTiles: []Glyph{{'"', ColorGreen}}, ImpassTiles: []Glyph{{'^', ColorGreen}}, PassChance: .9},
+			{PassTiles: []Glyph{{'.', ColorGreen}}, ImpassTiles: []Glyph{{'^', ColorGreen}}, PassChance: .7},
+			{PassTiles: []Glyph{{'"', ColorCyan}}, ImpassTiles: []Glyph{{'^', ColorGreen}}, PassChance: .8},
+		}, {
+			{PassTiles: []Glyph{{'"', ColorLightYellow}}, PassChance: 1},
+			{PassTiles: []Glyph{{'"', ColorLightGreen}}, ImpassTiles: []Glyph{{'%', ColorGreen}}, PassChance: .95},
+			{PassTiles: []Glyph{{'.', ColorGreen}}, ImpassTiles: []Glyph{{'%', ColorGreen}}, PassChance: .75},
+			{PassTiles: []Glyph{{'"', ColorCyan}}, ImpassTiles: []Glyph{{'%', ColorCyan}}, PassChance: .8},
+		}, {
+			{PassTiles: []Glyph{{'.', ColorLightYellow}}, ImpassTiles: []Glyph{{'%', ColorYellow}}, PassChance: .98},
+			{PassTiles: []Glyph{{'"', ColorYellow}}, ImpassTiles: []Glyph{{'%', ColorGreen}}, PassChance: .95},
+			{PassTiles: []Glyph{{'.', ColorLightGreen}}, ImpassTiles: []Glyph{{'%', ColorLightGreen}}, PassChance: .7},
+			{PassTiles: []Glyph{{'"', ColorLightGreen}}, ImpassTiles: []Glyph{{'%', ColorLightGreen}}, PassChance: .6},
+		},
+	},
+}
+
 // Heightmap is a grid of float64, with methods for manipulating the heightmap.
 type Heightmap struct {
 	cols, rows int
@@ -90,13 +196,19 @@ func NewHeightmap(cols, rows int) *Heightmap {
 	return &Heightmap{cols, rows, buf, cols / 8, rows / 8, cols + rows, true}
 }
 
-// Generate performs the full heightmap generation process.
-// Once the generation parameters have been set, this is what most users should
-// use to generate the heightmap, although more control is available through
-// the other methods.
+// Generate performs the full heightmap generation process using the global
+// Dice, as with GenerateWith.
 func (h *Heightmap) Generate() {
+	h.GenerateWith(globalDice)
+}
+
+// GenerateWith performs the full heightmap generation process, making every
+// random choice with the given Dice. Once the generation parameters have been
+// set, this is what most users should use to generate the heightmap, although
+// more control is available through the other methods.
+func (h *Heightmap) GenerateWith(d Dice) {
 	h.Reset()
-	h.RaiseEllipses()
+	h.RaiseEllipsesWith(d)
 	h.Smooth()
 	h.Equalize()
 	h.Normalize()
@@ -135,14 +247,21 @@ func (h *Heightmap) RaiseEllipse(center Offset) {
 	}
 }
 
-// RaiseEllipses will randomly raise ellipses on the map, thereby creating
+// RaiseEllipses will randomly raise ellipses on the map, as with
+// RaiseEllipsesWith, placing the ellipses using the global Dice.
+func (h *Heightmap) RaiseEllipses() {
+	h.RaiseEllipsesWith(globalDice)
+}
+
+// RaiseEllipsesWith will randomly raise ellipses on the map, thereby creating
 // terrain like height values. The number of ellipses is controlled with
 // NumEllipses. The size of the ellipses is controlled with RadiusX and
-// RadiusY. The ellipses will wrap around the x-axis if WrapX is true.
-func (h *Heightmap) RaiseEllipses() {
+// RadiusY. The ellipses will wrap around the x-axis if WrapX is true. The
+// ellipses are placed using the given Dice.
+func (h *Heightmap) RaiseEllipsesWith(d Dice) {
 	// Raise NumEllipses randomly placed ellipses.
 	for i := 0; i < h.NumEllipses; i++ {
-		h.RaiseEllipse(RandOffset(h.cols, h.rows))
+		h.RaiseEllipse(d.Offset(h.cols, h.rows))
 	}
 }
 
@@ -213,6 +332,15 @@ func (h *Heightmap) Apply(f MapGenFloat) []*Tile {
 	})
 }
 
+// WriteCells writes each cell of the Heightmap to a TileWriter, linked to its
+// neighbors as with Apply, using the kind function to choose the kind of each
+// cell from its height.
+func (h *Heightmap) WriteCells(w TileWriter, kind func(height float64) int) {
+	writeGrid(w, h.cols, h.rows, Offset{}, func(x, y int) int {
+		return kind(h.buf[x][y])
+	})
+}
+
 // Transform applies a transformation function to each value of the Heightmap.
 func (h *Heightmap) Transform(f func(float64) float64) {
 	for x := 0; x < h.cols; x++ {
@@ -261,5 +389,22 @@ func (h *Heightmap) Read(x, y int) float64 {
 	return h.buf[x][y]
 }
 
-// TODO Add temperature map based on latitude and elevation
-// TODO Add precipitation map based on latitude and elevation
+// latitude gives the distance of a row from the equator, which runs along the
+// middle row, from 0 at the equator to 1 at either pole.
+func (h *Heightmap) latitude(y int) float64 {
+	if h.rows <= 1 {
+		return 0
+	}
+	return math.Abs(2*float64(y)/float64(h.rows-1) - 1)
+}
+
+// cell gives the cell of the Heightmap for an Offset, assuming the Offset came
+// from a Tile created by Apply. Offsets outside the map wrap in x if WrapX is
+// set, and are otherwise clamped to the nearest cell.
+func (h *Heightmap) cell(o Offset) (x, y int) {
+	x, y = o.X, o.Y
+	if h.WrapX {
+		x = Mod(x, h.cols)
+	}
+	return Clamp(0, x, h.cols-1), Clamp(0, y, h.rows-1)
+}
@@ -0,0 +1,304 @@
+package core
+
+// CaveRule specifies the cellular automaton used to generate caves. Each cell
+// starts as wall with probability Fill. Then, on each of the Iterations, a
+// floor cell with at least Birth neighboring walls becomes wall, while a wall
+// cell with at least Survival neighboring walls remains wall. Once the
+// automaton finishes, any pockets disconnected from the largest cavern are
+// either filled in, or if Join is set, connected to the rest of the cave with
+// tunnels.
+type CaveRule struct {
+	Fill       float64
+	Birth      int
+	Survival   int
+	Iterations int
+	Join       bool
+}
+
+// DefaultCaveRule is the classic 4-5 rule, which creates open and organic
+// looking caverns.
+var DefaultCaveRule = CaveRule{Fill: .45, Birth: 5, Survival: 4, Iterations: 5}
+
+// Cave creates a set of Tile which form a natural looking cave, as with
+// CaveWith, choosing the starting cells with the global Dice.
+func (f MapGenBool) Cave(cols, rows int, rule CaveRule) []*Tile {
+	return f.CaveWith(globalDice, cols, rows, rule)
+}
+
+// CaveWith creates a set of Tile which form a natural looking cave, using
+// cellular automata with the given CaveRule. The cave is cols by rows Tiles,
+// including an outer ring of walls, and every passable Tile is connected. The
+// starting cells are chosen with the given Dice. If cols or rows is less than
+// 3, there is no room inside the wall ring, and nil is returned.
+func (f MapGenBool) CaveWith(d Dice, cols, rows int, rule CaveRule) []*Tile {
+	if cols < 3 || rows < 3 {
+		return nil
+	}
+	return applyCave(randomCaveCells(d, cols, rows, rule.Fill), Offset{}, rule, f)
+}
+
+// WriteCave writes a cave to a TileWriter as with WriteCaveWith, choosing the
+// starting cells with the global Dice.
+func WriteCave(w TileWriter, cols, rows int, rule CaveRule) {
+	WriteCaveWith(w, globalDice, cols, rows, rule)
+}
+
+// WriteCaveWith writes a cave to a TileWriter, as with Cave. Passable cells are
+// TileTypeCorridor, and walls are TileTypeWall. If cols or rows is less than
+// 3, nothing is written.
+func WriteCaveWith(w TileWriter, d Dice, cols, rows int, rule CaveRule) {
+	if cols < 3 || rows < 3 {
+		return
+	}
+	writeCave(w, randomCaveCells(d, cols, rows, rule.Fill), Offset{}, rule)
+}
+
+// Caveify roughens an existing set of Tile into a cave, as with CaveifyWith,
+// eroding the walls with the global Dice.
+func (f MapGenBool) Caveify(tiles []*Tile, rule CaveRule) []*Tile {
+	return f.CaveifyWith(globalDice, tiles, rule)
+}
+
+// CaveifyWith roughens an existing set of Tile, such as a maze or a dungeon,
+// into a cave. Walls bordering the floor are randomly eroded, remaining wall
+// with probability given by the Fill of the CaveRule, and then the cellular
+// automaton is run with the existing layout as the starting point. Only the
+// Offsets and passability of the given Tiles are used, so the Tiles should all
+// be on the same Level. The result is a new set of Tile covering the same area,
+// with an outer ring of walls, and every passable Tile connected. The walls are
+// eroded using the given Dice.
+func (f MapGenBool) CaveifyWith(d Dice, tiles []*Tile, rule CaveRule) []*Tile {
+	if len(tiles) == 0 {
+		return nil
+	}
+
+	// find the bounds of the existing Tiles, leaving room for a wall ring
+	min, max := TileBounds(tiles)
+	origin := min.Sub(Offset{1, 1})
+	cols, rows := max.X-min.X+3, max.Y-min.Y+3
+
+	// copy the existing layout, and randomly erode the walls bordering the
+	// floor, so that the automaton has something to smooth without closing off
+	// narrow corridors.
+	layout := newCaveCells(cols, rows)
+	for _, tile := range tiles {
+		o := tile.Offset.Sub(origin)
+		layout[o.X][o.Y] = tile.Pass
+	}
+	cells := newCaveCells(cols, rows)
+	for x := 1; x < cols-1; x++ {
+		for y := 1; y < rows-1; y++ {
+			cells[x][y] = layout[x][y]
+			if !layout[x][y] && caveNeighbors(layout, x, y, true) > 0 {
+				cells[x][y] = !d.Chance(rule.Fill)
+			}
+		}
+	}
+
+	return applyCave(cells, origin, rule, f)
+}
+
+// Cave creates a set of Tile which form a natural looking cave, using cellular
+// automata with the given CaveRule. The cave is cols by rows Tiles, including
+// an outer ring of walls, and every passable Tile is connected. Cave uses a
+// default MapGenBool which generates white '.' for passable Tile and white '#'
+// for wall Tile, and the global Dice.
+func Cave(cols, rows int, rule CaveRule) []*Tile {
+	return MapGenBool(defaultMapGenBool).Cave(cols, rows, rule)
+}
+
+// Caveify roughens an existing set of Tile, such as a maze or a dungeon, into
+// a cave, as with MapGenBool.Caveify. Caveify uses a default MapGenBool which
+// generates white '.' for passable Tile and white '#' for wall Tile, and the
+// global Dice.
+func Caveify(tiles []*Tile, rule CaveRule) []*Tile {
+	return MapGenBool(defaultMapGenBool).Caveify(tiles, rule)
+}
+
+// newCaveCells creates a cols by rows grid of cells, all of which are wall.
+func newCaveCells(cols, rows int) [][]bool {
+	cells := make([][]bool, cols)
+	for x := range cells {
+		cells[x] = make([]bool, rows)
+	}
+	return cells
+}
+
+// randomCaveCells creates a cols by rows grid of cells, with each cell inside
+// the outer ring being wall with probability fill.
+func randomCaveCells(d Dice, cols, rows int, fill float64) [][]bool {
+	cells := newCaveCells(cols, rows)
+	for x := 1; x < cols-1; x++ {
+		for y := 1; y < rows-1; y++ {
+			cells[x][y] = !d.Chance(fill)
+		}
+	}
+	return cells
+}
+
+// caveNeighbors counts the neighbors of a cell with the given passability.
+// Cells outside the grid count as wall.
+func caveNeighbors(cells [][]bool, x, y int, pass bool) int {
+	count := 0
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			nx, ny := x+dx, y+dy
+			inside := InBounds(nx, ny, len(cells), len(cells[0]))
+			if inside && cells[nx][ny] == pass || !inside && !pass {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// applyCave creates the Tiles for a cave using the MapGenBool, as with
+// writeCave.
+func applyCave(cells [][]bool, origin Offset, rule CaveRule, f MapGenBool) []*Tile {
+	w := NewTileGraphWriter(f.kinds())
+	writeCave(w, cells, origin, rule)
+	return w.Tiles()
+}
+
+// writeCave runs the cellular automaton on the cells, connects any pockets, and
+// then writes the cells of the cave, placing the first cell at the origin.
+func writeCave(w TileWriter, cells [][]bool, origin Offset, rule CaveRule) {
+	cols, rows := len(cells), len(cells[0])
+
+	// run the automaton, keeping the outer ring as wall
+	next := newCaveCells(cols, rows)
+	for i := 0; i < rule.Iterations; i++ {
+		for x := 1; x < cols-1; x++ {
+			for y := 1; y < rows-1; y++ {
+				walls := caveNeighbors(cells, x, y, false)
+				if cells[x][y] {
+					next[x][y] = walls < rule.Birth
+				} else {
+					next[x][y] = walls < rule.Survival
+				}
+			}
+		}
+		cells, next = next, cells
+	}
+
+	connectCave(cells, rule.Join)
+
+	writeGrid(w, cols, rows, origin, func(x, y int) int {
+		return boolKind(cells[x][y])
+	})
+}
+
+// labelCave labels each connected pocket of floor cells, returning the labels
+// (with -1 for wall) along with the size of each pocket.
+func labelCave(cells [][]bool) (labels [][]int, sizes []int) {
+	cols, rows := len(cells), len(cells[0])
+	labels = make([][]int, cols)
+	for x := range labels {
+		labels[x] = make([]int, rows)
+		for y := range labels[x] {
+			labels[x][y] = -1
+		}
+	}
+
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			if !cells[x][y] || labels[x][y] != -1 {
+				continue
+			}
+
+			// flood fill the pocket containing the cell
+			label := len(sizes)
+			sizes = append(sizes, 0)
+			labels[x][y] = label
+			frontier := []Offset{{x, y}}
+			for len(frontier) > 0 {
+				curr := frontier[len(frontier)-1]
+				frontier = frontier[:len(frontier)-1]
+				sizes[label]++
+
+				for _, step := range orthogonal {
+					adj := curr.Add(step)
+					if InBounds(adj.X, adj.Y, cols, rows) && cells[adj.X][adj.Y] && labels[adj.X][adj.Y] == -1 {
+						labels[adj.X][adj.Y] = label
+						frontier = append(frontier, adj)
+					}
+				}
+			}
+		}
+	}
+
+	return labels, sizes
+}
+
+// connectCave ensures that every floor cell is connected. If join is false,
+// every pocket except the largest is filled in. Otherwise, each smaller pocket
+// is connected by tunneling to the nearest other pocket.
+func connectCave(cells [][]bool, join bool) {
+	cols, rows := len(cells), len(cells[0])
+
+	for {
+		labels, sizes := labelCave(cells)
+		if len(sizes) <= 1 {
+			return
+		}
+
+		largest := 0
+		for label, size := range sizes {
+			if size > sizes[largest] {
+				largest = label
+			}
+		}
+
+		if !join {
+			for x := 0; x < cols; x++ {
+				for y := 0; y < rows; y++ {
+					if labels[x][y] != largest {
+						cells[x][y] = false
+					}
+				}
+			}
+			return
+		}
+
+		// tunnel outward from the first pocket which isn't the largest,
+		// using a breadth-first search from every cell in the pocket until
+		// the search reaches a different pocket.
+		pocket := 0
+		if pocket == largest {
+			pocket = 1
+		}
+		prev := make(map[Offset]Offset)
+		var frontier []Offset
+		for x := 0; x < cols; x++ {
+			for y := 0; y < rows; y++ {
+				if labels[x][y] == pocket {
+					prev[Offset{x, y}] = Offset{x, y}
+					frontier = append(frontier, Offset{x, y})
+				}
+			}
+		}
+		for len(frontier) > 0 {
+			curr := frontier[0]
+			frontier = frontier[1:]
+
+			if label := labels[curr.X][curr.Y]; label != -1 && label != pocket {
+				// carve the tunnel back to the pocket
+				for ; labels[curr.X][curr.Y] != pocket; curr = prev[curr] {
+					cells[curr.X][curr.Y] = true
+				}
+				break
+			}
+
+			for _, step := range orthogonal {
+				adj := curr.Add(step)
+				if _, seen := prev[adj]; !seen && InBounds(adj.X-1, adj.Y-1, cols-2, rows-2) {
+					prev[adj] = curr
+					frontier = append(frontier, adj)
+				}
+			}
+		}
+	}
+}
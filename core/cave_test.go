@@ -0,0 +1,93 @@
+package core
+
+import (
+	"testing"
+)
+
+// caveConnected returns true if every passable Tile in the cave is reachable
+// from every other passable Tile.
+func caveConnected(tiles []*Tile) bool {
+	regions := NewRegions(tiles, nil)
+	return regions.Count() == 1
+}
+
+func TestCave(t *testing.T) {
+	for _, join := range []bool{false, true} {
+		rule := DefaultCaveRule
+		rule.Join = join
+
+		tiles := Cave(40, 30, rule)
+		if len(tiles) != 40*30 {
+			t.Errorf("Cave gave %d Tiles, expected %d", len(tiles), 40*30)
+		}
+		if !caveConnected(tiles) {
+			t.Errorf("Cave with Join=%v was disconnected", join)
+		}
+		for _, tile := range tiles {
+			if tile.Pass && len(tile.Adjacent) != 8 {
+				t.Errorf("Cave has passable Tile on its edge")
+				break
+			}
+		}
+	}
+}
+
+func TestCaveSmall(t *testing.T) {
+	for _, dims := range []Offset{{0, 0}, {2, 10}, {10, 2}, {-1, 5}} {
+		if tiles := Cave(dims.X, dims.Y, DefaultCaveRule); tiles != nil {
+			t.Errorf("Cave(%d, %d) gave %d Tiles, expected nil", dims.X, dims.Y, len(tiles))
+		}
+		g := newCellGraph()
+		WriteCave(g, dims.X, dims.Y, DefaultCaveRule)
+		if len(g.order) != 0 {
+			t.Errorf("WriteCave(%d, %d) wrote %d cells, expected 0", dims.X, dims.Y, len(g.order))
+		}
+	}
+	if tiles := Cave(3, 3, DefaultCaveRule); len(tiles) != 9 {
+		t.Errorf("Cave(3, 3) gave %d Tiles, expected 9", len(tiles))
+	}
+}
+
+func TestCaveJoin(t *testing.T) {
+	// two pockets separated by a thick wall, which the automaton won't change
+	cells := newCaveCells(12, 5)
+	for y := 1; y < 4; y++ {
+		cells[1][y], cells[2][y], cells[3][y] = true, true, true
+		cells[8][y], cells[9][y], cells[10][y] = true, true, true
+	}
+
+	connectCave(cells, true)
+	if _, sizes := labelCave(cells); len(sizes) != 1 || sizes[0] != 22 {
+		t.Errorf("connectCave did not tunnel between pockets: %v", sizes)
+	}
+
+	cells = newCaveCells(12, 5)
+	for y := 1; y < 4; y++ {
+		cells[1][y], cells[2][y], cells[3][y] = true, true, true
+		cells[8][y], cells[9][y] = true, true
+	}
+	connectCave(cells, false)
+	if _, sizes := labelCave(cells); len(sizes) != 1 || sizes[0] != 9 {
+		t.Errorf("connectCave did not fill smaller pocket: %v", sizes)
+	}
+}
+
+func TestCaveify(t *testing.T) {
+	maze := HalfBraidMaze(40, .5, 0, .5)
+	cave := Caveify(maze, DefaultCaveRule)
+	if !caveConnected(cave) {
+		t.Errorf("Caveify gave disconnected cave")
+	}
+
+	// the cave should cover the same area as the maze, plus the wall ring
+	byOffset := make(map[Offset]*Tile)
+	for _, tile := range cave {
+		byOffset[tile.Offset] = tile
+	}
+	for _, tile := range maze {
+		if _, ok := byOffset[tile.Offset]; !ok {
+			t.Errorf("Caveify did not cover %v", tile.Offset)
+			break
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"math"
+)
+
+// TemperatureMap is a temperature layer for an overworld generated from a
+// Heightmap. The temperature falls from the Equator value at the middle row to
+// the Pole value at the top and bottom rows, and falls by LapseRate for each
+// unit of height above the SeaLevel. Some random variation, with an amplitude
+// of Noise, is added so that the isotherms are not perfectly straight.
+//
+// The Season shifts the temperature throughout the year. The Season is a phase
+// in [0, 1), with 0 and .5 being the equinoxes, and .25 being midsummer in the
+// northern hemisphere (and midwinter in the south). The size of the seasonal
+// swing is SeasonalRange at the poles, and shrinks to nothing at the equator.
+// Since a TemperatureMap handles Tick, it can be scheduled on a DeltaClock so
+// that the Season advances by SeasonRate on each Tick.
+type TemperatureMap struct {
+	height *Heightmap
+	base   [][]float64
+
+	Equator, Pole float64
+	LapseRate     float64
+	SeaLevel      float64
+	Noise         float64
+	SeasonalRange float64
+	Season        float64
+	SeasonRate    float64
+}
+
+// NewTemperatureMap creates a TemperatureMap for the given Heightmap, with
+// default parameters giving temperatures in degrees Celsius. Generate must be
+// called after the Heightmap is generated, and before any temperature is read.
+func NewTemperatureMap(h *Heightmap) *TemperatureMap {
+	base := make([][]float64, h.cols)
+	for x := range base {
+		base[x] = make([]float64, h.rows)
+	}
+	return &TemperatureMap{
+		height:        h,
+		base:          base,
+		Equator:       30,
+		Pole:          -25,
+		LapseRate:     40,
+		SeaLevel:      .4,
+		Noise:         5,
+		SeasonalRange: 15,
+		SeasonRate:    1.0 / 360,
+	}
+}
+
+// Generate computes the temperature of each cell, as with GenerateWith, using
+// the global Dice for the random variation.
+func (m *TemperatureMap) Generate() {
+	m.GenerateWith(globalDice)
+}
+
+// GenerateWith computes the temperature of each cell, apart from the seasonal
+// shift, using the current values of the Heightmap. The random variation is
+// generated with the given Dice.
+func (m *TemperatureMap) GenerateWith(d Dice) {
+	var noise *Heightmap
+	if m.Noise != 0 {
+		noise = NewHeightmap(m.height.cols, m.height.rows)
+		noise.WrapX = m.height.WrapX
+		noise.GenerateWith(d)
+	}
+
+	for x := 0; x < m.height.cols; x++ {
+		for y := 0; y < m.height.rows; y++ {
+			temp := m.Equator + (m.Pole-m.Equator)*m.height.latitude(y)
+			temp -= m.LapseRate * math.Max(0, m.height.buf[x][y]-m.SeaLevel)
+			if noise != nil {
+				temp += m.Noise * (2*noise.buf[x][y] - 1)
+			}
+			m.base[x][y] = temp
+		}
+	}
+}
+
+// Read gets the temperature of a specific cell, including the seasonal shift.
+func (m *TemperatureMap) Read(x, y int) float64 {
+	swing := m.SeasonalRange * m.height.latitude(y) * math.Sin(2*math.Pi*m.Season)
+	if 2*y >= m.height.rows {
+		swing = -swing
+	}
+	return m.base[x][y] + swing
+}
+
+// Mean gets the temperature of a specific cell averaged over the year, meaning
+// without the seasonal shift.
+func (m *TemperatureMap) Mean(x, y int) float64 {
+	return m.base[x][y]
+}
+
+// Temperature gets the temperature where a Tile stands, assuming that the Tile
+// was created by applying the Heightmap, so that its Offset gives its cell.
+// Tiles outside the map, such as those of an embedded dungeon, use the nearest
+// cell.
+func (m *TemperatureMap) Temperature(t *Tile) float64 {
+	x, y := m.height.cell(t.Offset)
+	return m.Read(x, y)
+}
+
+// Handle implements Entity for TemperatureMap, so that a TemperatureMap can be
+// scheduled on a DeltaClock. Each Tick Event advances the Season.
+func (m *TemperatureMap) Handle(v Event) {
+	if _, ok := v.(*Tick); ok {
+		m.Season = math.Mod(m.Season+m.SeasonRate, 1)
+	}
+}
+
+// MoistureMap is a moisture layer for an overworld generated from a Heightmap,
+// with values in [0, 1]. Part of the moisture comes from latitude bands, with
+// wet tropics and mid-latitudes, and dry subtropics and poles. The rest comes
+// from rainfall carried by the prevailing winds, which blow east in the
+// mid-latitudes and west elsewhere. The wind picks up moisture over water below
+// the SeaLevel, and drops it as rain over land, especially when forced up the
+// slopes of high terrain. This leaves a dry rain shadow behind mountains.
+type MoistureMap struct {
+	height *Heightmap
+	buf    [][]float64
+
+	SeaLevel    float64
+	Evaporation float64
+	Rainfall    float64
+	Orographic  float64
+	Banding     float64
+}
+
+// NewMoistureMap creates a MoistureMap for the given Heightmap with default
+// parameters. Generate must be called after the Heightmap is generated, and
+// before any moisture is read.
+func NewMoistureMap(h *Heightmap) *MoistureMap {
+	buf := make([][]float64, h.cols)
+	for x := range buf {
+		buf[x] = make([]float64, h.rows)
+	}
+	return &MoistureMap{
+		height:      h,
+		buf:         buf,
+		SeaLevel:    .4,
+		Evaporation: .2,
+		Rainfall:    .05,
+		Orographic:  2,
+		Banding:     .4,
+	}
+}
+
+// Generate computes the moisture of each cell using the current values of the
+// Heightmap.
+func (m *MoistureMap) Generate() {
+	cols, rows := m.height.cols, m.height.rows
+
+	// blow the wind across each row, starting from the upwind edge. If the
+	// map wraps, the wind crosses the row twice so that moisture carried
+	// across the seam is accounted for.
+	passes := 1
+	if m.height.WrapX {
+		passes = 2
+	}
+	maxRain := 0.0
+	for y := 0; y < rows; y++ {
+		lat := m.height.latitude(y)
+		start, dir := cols-1, -1
+		if lat > 1.0/3 && lat < 2.0/3 {
+			start, dir = 0, 1
+		}
+
+		carry, prev := 0.0, math.Max(m.height.buf[start][y], m.SeaLevel)
+		for i := 0; i < passes*cols; i++ {
+			x := Mod(start+dir*i, cols)
+			height := m.height.buf[x][y]
+
+			var rain float64
+			if height < m.SeaLevel {
+				carry = math.Min(1, carry+m.Evaporation)
+				rain = carry * m.Rainfall
+			} else {
+				lift := math.Max(0, height-prev)
+				rain = carry * math.Min(1, m.Rainfall+m.Orographic*lift)
+				carry -= rain
+			}
+			prev = math.Max(height, m.SeaLevel)
+
+			if i >= (passes-1)*cols {
+				m.buf[x][y] = rain
+				maxRain = math.Max(maxRain, rain)
+			}
+		}
+	}
+
+	// combine the normalized rainfall with the latitude bands
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			band := .5 + .5*math.Cos(3*math.Pi*m.height.latitude(y))
+			rain := 0.0
+			if maxRain > 0 {
+				rain = m.buf[x][y] / maxRain
+			}
+			if m.height.buf[x][y] < m.SeaLevel {
+				rain = 1
+			}
+			m.buf[x][y] = m.Banding*band + (1-m.Banding)*rain
+		}
+	}
+}
+
+// Read gets the moisture of a specific cell.
+func (m *MoistureMap) Read(x, y int) float64 {
+	return m.buf[x][y]
+}
+
+// Moisture gets the moisture where a Tile stands, assuming that the Tile was
+// created by applying the Heightmap, so that its Offset gives its cell. Tiles
+// outside the map use the nearest cell.
+func (m *MoistureMap) Moisture(t *Tile) float64 {
+	x, y := m.height.cell(t.Offset)
+	return m.Read(x, y)
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// flatHeightmap creates a Heightmap with every cell at the given height.
+func flatHeightmap(cols, rows int, height float64) *Heightmap {
+	h := NewHeightmap(cols, rows)
+	h.Transform(func(float64) float64 { return height })
+	return h
+}
+
+func TestTemperatureMapLatitude(t *testing.T) {
+	h := flatHeightmap(4, 11, .2)
+	m := NewTemperatureMap(h)
+	m.Noise = 0
+	m.Generate()
+
+	if actual := m.Read(0, 5); actual != m.Equator {
+		t.Errorf("equator temperature %v, expected %v", actual, m.Equator)
+	}
+	if actual := m.Read(0, 0); actual != m.Pole {
+		t.Errorf("north pole temperature %v, expected %v", actual, m.Pole)
+	}
+	if actual := m.Read(0, 10); actual != m.Pole {
+		t.Errorf("south pole temperature %v, expected %v", actual, m.Pole)
+	}
+	for y := 1; y <= 5; y++ {
+		if m.Read(0, y) <= m.Read(0, y-1) {
+			t.Errorf("temperature did not rise towards the equator at row %d", y)
+		}
+	}
+}
+
+func TestTemperatureMapElevation(t *testing.T) {
+	h := flatHeightmap(4, 11, .2)
+	h.Write(1, 5, .9)
+	m := NewTemperatureMap(h)
+	m.Noise = 0
+	m.Generate()
+
+	expected := m.Equator - m.LapseRate*(.9-m.SeaLevel)
+	if actual := m.Read(1, 5); math.Abs(actual-expected) > 1e-9 {
+		t.Errorf("mountain temperature %v, expected %v", actual, expected)
+	}
+}
+
+func TestTemperatureMapSeason(t *testing.T) {
+	h := flatHeightmap(4, 11, .2)
+	m := NewTemperatureMap(h)
+	m.Noise = 0
+	m.Generate()
+
+	// midsummer in the north is midwinter in the south
+	m.Season = .25
+	if actual := m.Read(0, 0); math.Abs(actual-(m.Pole+m.SeasonalRange)) > 1e-9 {
+		t.Errorf("northern summer temperature %v", actual)
+	}
+	if actual := m.Read(0, 10); math.Abs(actual-(m.Pole-m.SeasonalRange)) > 1e-9 {
+		t.Errorf("southern winter temperature %v", actual)
+	}
+	if actual := m.Read(0, 5); math.Abs(actual-m.Equator) > 1e-9 {
+		t.Errorf("equator changed with season to %v", actual)
+	}
+
+	// the season advances with each Tick, wrapping at the end of the year
+	m.Season, m.SeasonRate = .9, .2
+	m.Handle(&Tick{})
+	if math.Abs(m.Season-.1) > 1e-9 {
+		t.Errorf("Tick advanced season to %v, expected .1", m.Season)
+	}
+}
+
+func TestTemperatureMapTile(t *testing.T) {
+	h := NewHeightmap(20, 10)
+	h.Generate()
+	m := NewTemperatureMap(h)
+	m.Generate()
+
+	tiles := h.Apply(func(o Offset, height float64) *Tile { return NewTile(o) })
+	for _, tile := range tiles {
+		if m.Temperature(tile) != m.Read(tile.Offset.X, tile.Offset.Y) {
+			t.Errorf("Temperature disagreed with Read at %v", tile.Offset)
+		}
+	}
+
+	// Offsets past the edge wrap in x and clamp in y
+	if m.Temperature(NewTile(Offset{21, -3})) != m.Read(1, 0) {
+		t.Errorf("Temperature did not wrap or clamp out of bounds Offset")
+	}
+}
+
+func TestMoistureMapBands(t *testing.T) {
+	h := flatHeightmap(4, 9, 0)
+	h.WrapX = false
+	m := NewMoistureMap(h)
+	m.Generate()
+
+	// wet tropics and mid-latitudes, dry subtropics and poles
+	equator, subtropic, temperate, pole := m.Read(0, 4), m.Read(0, 3), m.Read(0, 1), m.Read(0, 0)
+	if !(equator > temperate && temperate > subtropic && subtropic > pole) {
+		t.Errorf("MoistureMap bands out of order: %v %v %v %v", equator, subtropic, temperate, pole)
+	}
+}
+
+func TestMoistureMapRainShadow(t *testing.T) {
+	// the wind blows east in row 2, across a sea, a plain and a mountain, and
+	// then blows west across the other sea in the tropics
+	h := flatHeightmap(30, 9, .5)
+	h.WrapX = false
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 9; y++ {
+			if x < 5 || x >= 25 {
+				h.Write(x, y, .1)
+			} else if x == 15 {
+				h.Write(x, y, .9)
+			}
+		}
+	}
+	m := NewMoistureMap(h)
+	m.Generate()
+
+	windward, leeward := m.Read(15, 2), m.Read(20, 2)
+	if windward <= leeward {
+		t.Errorf("no rain shadow in westerlies: windward %v, leeward %v", windward, leeward)
+	}
+	if m.Read(6, 2) <= leeward {
+		t.Errorf("coast %v was not wetter than rain shadow %v", m.Read(6, 2), leeward)
+	}
+
+	// the wind blows west in the tropics, so the shadow is on the other side
+	if m.Read(15, 4) <= m.Read(10, 4) {
+		t.Errorf("rain shadow in tropics fell on wrong side")
+	}
+}
+
+func TestBiomeTable(t *testing.T) {
+	biome := func(ch rune) Biome {
+		return Biome{PassTiles: []Glyph{{ch, ColorWhite}}, PassChance: 1}
+	}
+	table := BiomeTable{
+		Temperatures: []float64{0, 20},
+		Moistures:    []float64{.5, 1},
+		Biomes: [][]Biome{
+			{biome('t'), biome('b')},
+			{biome('d'), biome('s')},
+		},
+		SeaLevel: .4,
+		Sea:      BiomeList{{Boundary: .4, ImpassTiles: []Glyph{{'~', ColorBlue}}}},
+	}
+
+	cases := []struct {
+		temperature, moisture float64
+		expected              rune
+	}{
+		{-10, .2, 't'},
+		{-10, .8, 'b'},
+		{10, .2, 'd'},
+		{30, .9, 's'},
+	}
+	for _, c := range cases {
+		actual := table.Select(c.temperature, c.moisture).PassTiles[0].Ch
+		if actual != c.expected {
+			t.Errorf("Select(%v, %v) = %c, expected %c", c.temperature, c.moisture, actual, c.expected)
+		}
+	}
+
+	// with no moisture bounds, the table varies by temperature alone
+	single := BiomeTable{Temperatures: []float64{0, 20}, Biomes: [][]Biome{{biome('t')}, {biome('d')}}}
+	if actual := single.Select(10, .5).PassTiles[0].Ch; actual != 'd' {
+		t.Errorf("Select with empty Moistures = %c, expected d", actual)
+	}
+	if band(nil, 5) != 0 {
+		t.Errorf("band with empty bounds = %d, expected 0", band(nil, 5))
+	}
+
+	h := flatHeightmap(4, 9, .5)
+	h.Write(0, 0, .1)
+	temp, moist := NewTemperatureMap(h), NewMoistureMap(h)
+	temp.Noise = 0
+	temp.Generate()
+	moist.Generate()
+	gen := table.NewMapGen(temp, moist)
+	if tile := gen(Offset{0, 0}, .1); tile.Face.Ch != '~' {
+		t.Errorf("NewMapGen gave %c below sea level", tile.Face.Ch)
+	}
+	if tile := gen(Offset{1, 0}, .5); tile.Face.Ch != 't' && tile.Face.Ch != 'b' {
+		t.Errorf("NewMapGen gave %c at the pole", tile.Face.Ch)
+	}
+	if tile := gen(Offset{1, 4}, .5); tile.Face.Ch != 'd' && tile.Face.Ch != 's' {
+		t.Errorf("NewMapGen gave %c at the equator", tile.Face.Ch)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"math"
 )
 
-// deltanode stores Entity events for a particular delta in a DeltaClock.
+// deltanode stores Entity events for a particular delta in a DeltaClock. The
+// order records the Entity in the order they were scheduled, so that Tick
+// always sends its Events in the same order.
 type deltanode struct {
 	delta  float64
 	link   *deltanode
 	events map[Entity]struct{}
+	order  []Entity
 }
 
 // DeltaClock implements a data structure which allows for fast scheduling.
@@ -57,7 +60,7 @@ func (c *DeltaClock) Schedule(e Entity, delta float64) {
 		node = curr
 	} else {
 		// desired node didn't exist, so create it, with a link to curr node
-		node = &deltanode{delta, curr, make(map[Entity]struct{})}
+		node = &deltanode{delta, curr, make(map[Entity]struct{}), nil}
 
 		if prev == nil {
 			// prev == nil iff we're at the beginning of the list
@@ -74,6 +77,9 @@ func (c *DeltaClock) Schedule(e Entity, delta float64) {
 	}
 
 	// add the event to the node
+	if _, ok := node.events[e]; !ok {
+		node.order = append(node.order, e)
+	}
 	node.events[e] = struct{}{}
 	c.nodes[e] = node
 }
@@ -84,6 +90,12 @@ func (c *DeltaClock) Unschedule(e Entity) {
 	if node, ok := c.nodes[e]; ok {
 		delete(node.events, e)
 		delete(c.nodes, e)
+		for i, scheduled := range node.order {
+			if scheduled == e {
+				node.order = append(node.order[:i], node.order[i+1:]...)
+				break
+			}
+		}
 	}
 }
 
@@ -107,4 +119,32 @@ func (c *DeltaClock) Advance() map[Entity]struct{} {
 	return events
 }
 
+// Tick advances the clock as with Advance, and then sends a Tick Event to each
+// of the advanced Entity in the order in which they were scheduled, so that
+// the same schedule always updates in the same order. The Entity are returned
+// in that order, and Entity which simply need to update once per scheduled
+// delta, such as a ScentMap, can then be rescheduled by the caller just like
+// any other Entity.
+func (c *DeltaClock) Tick() []Entity {
+	if c.head == nil {
+		return nil
+	}
+
+	order := c.head.order
+	events := c.Advance()
+	ticked := make([]Entity, 0, len(events))
+	for _, e := range order {
+		if _, ok := events[e]; ok {
+			ticked = append(ticked, e)
+			e.Handle(&Tick{})
+		}
+	}
+	return ticked
+}
+
+// Tick is an Event informing an Entity that its scheduled time on a DeltaClock
+// has arrived, and is sent by DeltaClock.Tick. Entity which simply need to
+// update once per scheduled delta, such as a ScentMap, should handle Tick.
+type Tick struct{}
+
 // TODO Add distance based delay calculator
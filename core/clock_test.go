@@ -1,6 +1,7 @@
 package core
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -60,3 +61,68 @@ func TestDeltaClock_Unschedule(t *testing.T) {
 	schedule := [][]Entity{{e1}, {}, {e1}}
 	checkSchedule(t, c, schedule, speeds)
 }
+
+func TestDeltaClock_Tick(t *testing.T) {
+	scent, temp := NewScentMap(), NewTemperatureMap(NewHeightmap(1, 1))
+	tile := NewTile(Offset{})
+	scent.Deposit(tile, 1)
+	c := initClock(map[Entity]float64{scent: 1, temp: 2})
+
+	if events := c.Tick(); !reflect.DeepEqual(events, []Entity{scent}) {
+		t.Errorf("Tick gave %v, expected only the ScentMap", events)
+	}
+	if scent.Scent(tile) != .9 {
+		t.Errorf("Tick decayed scent to %v, expected .9", scent.Scent(tile))
+	}
+	if temp.Season != 0 {
+		t.Errorf("Tick advanced an Entity which was not due")
+	}
+
+	c.Tick()
+	if temp.Season != temp.SeasonRate {
+		t.Errorf("Tick advanced season to %v, expected %v", temp.Season, temp.SeasonRate)
+	}
+}
+
+// tickOrder is a Component which records the order in which Tick is received.
+type tickOrder struct {
+	order *[]Entity
+	e     Entity
+}
+
+// Process implements Component for tickOrder.
+func (c *tickOrder) Process(v Event) {
+	if _, ok := v.(*Tick); ok {
+		*c.order = append(*c.order, c.e)
+	}
+}
+
+func TestDeltaClock_TickOrder(t *testing.T) {
+	var order []Entity
+	entities := make([]Entity, 10)
+	for i := range entities {
+		e := &ComponentSlice{}
+		*e = append(*e, &tickOrder{&order, e})
+		entities[i] = e
+	}
+
+	c := NewDeltaClock()
+	for _, e := range entities {
+		c.Schedule(e, 1)
+	}
+	c.Unschedule(entities[3])
+	c.Schedule(entities[3], 1)
+
+	expected := append(append([]Entity{}, entities[:3]...), entities[4:]...)
+	expected = append(expected, entities[3])
+	for round := 0; round < 3; round++ {
+		order = nil
+		ticked := c.Tick()
+		if !reflect.DeepEqual(ticked, expected) || !reflect.DeepEqual(order, expected) {
+			t.Fatalf("Tick did not send Tick in the order scheduled")
+		}
+		for _, e := range ticked {
+			c.Schedule(e, 1)
+		}
+	}
+}
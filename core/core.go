@@ -181,4 +181,11 @@ func (e Error) Error() string {
 // Custom stones errors to explicitly check against.
 var (
 	ErrInvalidDimensions = Error("grid: invalid dimensions")
+	ErrSameLevel         = Error("stairs: tiles on same level")
+	ErrNoEntrance        = Error("embed: no entrance opened")
+	ErrPrefabShape       = Error("prefab: template is not rectangular")
+	ErrPrefabLegend      = Error("prefab: character missing from legend")
+	ErrNoSite            = Error("prefab: no site fits prefab")
+	ErrMapFormat         = Error("map: invalid map data")
+	ErrMapVersion        = Error("map: unsupported format version")
 )
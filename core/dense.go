@@ -0,0 +1,257 @@
+package core
+
+// TileIndex assigns each Tile in a map a compact index, so that per-Tile data
+// can be stored in slices instead of maps. If the Tiles were created by
+// NewTileGrid (such as by Heightmap.Apply), then Tiles are located using their
+// Offset, otherwise a map lookup is used. The adjacency of each Tile is cached
+// when the TileIndex is created, so if the Adjacent maps change, a new
+// TileIndex should be created. Changes to Pass do not require a new TileIndex.
+// Stairs are not cached, so a TileIndex describes a single Level.
+type TileIndex struct {
+	tiles []*Tile
+
+	// grid lookup for Tiles in NewTileGrid order, otherwise a map lookup
+	grid       bool
+	origin     Offset
+	cols, rows int
+	lookup     map[*Tile]int
+
+	// adjacency cached in compressed sparse row format, so the neighbors of
+	// the ith Tile are adj[start[i]:start[i+1]]
+	start []int
+	adj   []denseAdj
+}
+
+// denseAdj is a cached adjacency in a TileIndex.
+type denseAdj struct {
+	offset Offset
+	index  int
+}
+
+// NewTileIndex creates a TileIndex for the given Tiles.
+func NewTileIndex(tiles []*Tile) *TileIndex {
+	x := &TileIndex{tiles: tiles}
+
+	if x.grid = isTileGrid(tiles); x.grid {
+		x.origin = tiles[0].Offset
+		last := tiles[len(tiles)-1].Offset.Sub(x.origin)
+		x.cols, x.rows = last.X+1, last.Y+1
+	} else {
+		x.lookup = make(map[*Tile]int, len(tiles))
+		for i, tile := range tiles {
+			x.lookup[tile] = i
+		}
+	}
+
+	x.start = make([]int, len(tiles)+1)
+	for i, tile := range tiles {
+		for offset, adj := range tile.Adjacent {
+			if j, ok := x.Index(adj); ok {
+				x.adj = append(x.adj, denseAdj{offset, j})
+			}
+		}
+		x.start[i+1] = len(x.adj)
+	}
+
+	return x
+}
+
+// isTileGrid returns true if the Tiles are in the order given by NewTileGrid.
+func isTileGrid(tiles []*Tile) bool {
+	if len(tiles) == 0 {
+		return false
+	}
+
+	origin := tiles[0].Offset
+	last := tiles[len(tiles)-1].Offset.Sub(origin)
+	cols, rows := last.X+1, last.Y+1
+	if cols <= 0 || rows <= 0 || cols*rows != len(tiles) {
+		return false
+	}
+
+	for i, tile := range tiles {
+		if tile.Offset != origin.Add(Offset{i / rows, i % rows}) {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of Tiles in the TileIndex.
+func (x *TileIndex) Len() int {
+	return len(x.tiles)
+}
+
+// Tile returns the Tile with the given index.
+func (x *TileIndex) Tile(i int) *Tile {
+	return x.tiles[i]
+}
+
+// Index returns the index of the given Tile. If the Tile is not part of the
+// TileIndex, ok will be false.
+func (x *TileIndex) Index(t *Tile) (i int, ok bool) {
+	if !x.grid {
+		i, ok = x.lookup[t]
+		return i, ok
+	}
+
+	o := t.Offset.Sub(x.origin)
+	if !InBounds(o.X, o.Y, x.cols, x.rows) {
+		return 0, false
+	}
+	i = o.X*x.rows + o.Y
+	return i, x.tiles[i] == t
+}
+
+// DenseField is a WeightedField whose weights are stored in a slice using a
+// TileIndex. Unlike AttractiveField, a DenseField can be recomputed in place,
+// reusing its memory, which makes it suitable for large maps in which fields
+// must be recomputed every turn. Since the TileIndex does not cache Stairs, a
+// DenseField does not spread between Levels.
+type DenseField struct {
+	index   *TileIndex
+	weights []float64
+	seen    []bool
+	touched []int
+	queue   []int
+	heap    weightqueue
+}
+
+// NewDenseField creates a DenseField with every weight set to 0.
+func NewDenseField(index *TileIndex) *DenseField {
+	n := index.Len()
+	return &DenseField{index, make([]float64, n), make([]bool, n), nil, nil, nil}
+}
+
+// reset sets the weights of any Tile touched by the previous computation to 0.
+func (f *DenseField) reset() {
+	for _, i := range f.touched {
+		f.weights[i] = 0
+		f.seen[i] = false
+	}
+	f.touched = f.touched[:0]
+}
+
+// visit sets the weight for the ith Tile and marks it as touched.
+func (f *DenseField) visit(i int, weight float64) {
+	if !f.seen[i] {
+		f.seen[i] = true
+		f.touched = append(f.touched, i)
+	}
+	f.weights[i] = weight
+}
+
+// Attract recomputes the DenseField so that it pulls towards the given goals.
+// On a single Level, the resulting weights are identical to those of
+// AttractiveField.
+func (f *DenseField) Attract(radius int, goals ...*Tile) {
+	f.reset()
+
+	// setup breadth-first bookkeeping, reusing the queue from last time
+	queue := f.queue[:0]
+	for _, goal := range goals {
+		if i, ok := f.index.Index(goal); ok {
+			f.visit(i, float64(-radius))
+			queue = append(queue, i)
+		}
+	}
+
+	for head := 0; head < len(queue); head++ {
+		curr := queue[head]
+
+		// if we've reached edge field, stop expanding the field
+		cost := f.weights[curr] + 1
+		if cost > 0 {
+			continue
+		}
+
+		// expand the frontier using neighbors of curr
+		for _, adj := range f.index.adj[f.index.start[curr]:f.index.start[curr+1]] {
+			if !f.seen[adj.index] && f.index.tiles[adj.index].Pass {
+				f.visit(adj.index, cost)
+				queue = append(queue, adj.index)
+			}
+		}
+	}
+
+	f.queue = queue
+}
+
+// WeightedAttract recomputes the DenseField so that it pulls towards the given
+// goals, with the cost of each step given by a DistFn. On a single Level, the
+// resulting weights are identical to those of WeightedAttractiveField.
+func (f *DenseField) WeightedAttract(radius float64, cost DistFn, goals ...*Tile) {
+	f.reset()
+
+	// setup Dijkstra bookkeeping, reusing the queue from last time
+	queue := &f.heap
+	*queue = (*queue)[:0]
+	for _, goal := range goals {
+		if i, ok := f.index.Index(goal); ok {
+			f.visit(i, -radius)
+			queue.push(weightentry{index: i, weight: -radius})
+		}
+	}
+
+	for queue.Len() > 0 {
+		// pop the next Tile, skipping it if we've since found a better weight
+		entry := queue.pop()
+		curr := entry.index
+		if entry.weight > f.weights[curr] {
+			continue
+		}
+
+		currTile := f.index.tiles[curr]
+		for _, adj := range f.index.adj[f.index.start[curr]:f.index.start[curr+1]] {
+			adjTile := f.index.tiles[adj.index]
+			if !adjTile.Pass {
+				continue
+			}
+			weight := f.weights[curr] + cost(currTile, adjTile)
+			if weight > 0 {
+				continue
+			}
+			if !f.seen[adj.index] || weight < f.weights[adj.index] {
+				f.visit(adj.index, weight)
+				queue.push(weightentry{index: adj.index, weight: weight})
+			}
+		}
+	}
+}
+
+// Weight implements WeightedField for DenseField.
+func (f *DenseField) Weight(t *Tile) float64 {
+	if i, ok := f.index.Index(t); ok {
+		return f.weights[i]
+	}
+	return 0
+}
+
+// reached returns true if the last computation of the DenseField reached the
+// given Tile.
+func (f *DenseField) reached(t *Tile) bool {
+	i, ok := f.index.Index(t)
+	return ok && f.seen[i]
+}
+
+// Follow returns an Offset from the given Tile which will lead to the
+// neighboring tile which has a lower weight. Goal weights are negative
+// so that the default value of 0 is neutral. As with AttractiveField, Follow
+// only steps onto Tile which the field reached, whether or not the given Tile
+// is in the TileIndex.
+func (f *DenseField) Follow(t *Tile) Offset {
+	i, ok := f.index.Index(t)
+	if !ok {
+		return (&weightField{f.Weight, []Field{f}}).Follow(t)
+	}
+
+	minWeight, minOffset := f.weights[i], Offset{}
+	for _, adj := range f.index.adj[f.index.start[i]:f.index.start[i+1]] {
+		if weight := f.weights[adj.index]; weight < minWeight && f.seen[adj.index] {
+			minWeight = weight
+			minOffset = adj.offset
+		}
+	}
+
+	return minOffset
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"testing"
+)
+
+// denseTestGrid creates a grid of Tile with some random impassable Tile.
+func denseTestGrid(cols, rows int) []*Tile {
+	dice := NewDice(newXorshift(1))
+	return NewTileGrid(cols, rows, Offset{3, -2}, func(o Offset) *Tile {
+		t := NewTile(o)
+		t.Pass = !dice.Chance(.2)
+		return t
+	})
+}
+
+func TestTileIndex(t *testing.T) {
+	grid := denseTestGrid(10, 20)
+	maze := PerfectMaze(50, .5, 0)
+	for _, tiles := range [][]*Tile{grid, maze} {
+		index := NewTileIndex(tiles)
+		if index.Len() != len(tiles) {
+			t.Errorf("TileIndex has length %d != %d", index.Len(), len(tiles))
+		}
+		for i, tile := range tiles {
+			if j, ok := index.Index(tile); !ok || i != j || index.Tile(j) != tile {
+				t.Errorf("TileIndex gave incorrect index %d != %d", j, i)
+			}
+		}
+		if _, ok := index.Index(NewTile(Offset{4, -1})); ok {
+			t.Errorf("TileIndex indexed unknown Tile")
+		}
+	}
+	if !NewTileIndex(grid).grid || NewTileIndex(maze).grid {
+		t.Errorf("TileIndex did not detect grid correctly")
+	}
+}
+
+func TestDenseField(t *testing.T) {
+	tiles := denseTestGrid(30, 40)
+	index := NewTileIndex(tiles)
+	dense := NewDenseField(index)
+	goals := []*Tile{tiles[0], tiles[500], tiles[1000]}
+
+	for _, radius := range []int{5, 20, 10} {
+		sparse := AttractiveField(radius, goals...)
+		dense.Attract(radius, goals...)
+		for _, tile := range tiles {
+			if FieldWeight(sparse, tile) != dense.Weight(tile) {
+				t.Errorf("DenseField Attract weight mismatch at %v", tile.Offset)
+			}
+			if step := dense.Follow(tile); step != (Offset{}) && FieldWeight(sparse, tile.Adjacent[step]) > FieldWeight(sparse, tile) {
+				t.Errorf("DenseField Follow stepped uphill at %v", tile.Offset)
+			}
+		}
+	}
+
+	sparse := WeightedAttractiveField(15, euclidean, goals...)
+	dense.WeightedAttract(15, euclidean, goals...)
+	for _, tile := range tiles {
+		if FieldWeight(sparse, tile) != dense.Weight(tile) {
+			t.Errorf("DenseField WeightedAttract weight mismatch at %v", tile.Offset)
+		}
+	}
+}
+
+func TestDenseFieldFollowAgrees(t *testing.T) {
+	// the goal is impassable, and the outside Tile is not in the TileIndex
+	origin, goal := MovementCase(StrGrid{
+		"#####",
+		"#@.$#",
+		"#####",
+	})
+	goal.Pass = false
+	tiles := []*Tile{origin, origin.Adjacent[Offset{1, 0}], goal}
+	outside := NewTile(goal.Offset.Add(Offset{0, 1}))
+	outside.Adjacent[Offset{0, -1}] = goal
+
+	field := NewDenseField(NewTileIndex(tiles))
+	field.Attract(5, goal)
+	if step := field.Follow(tiles[1]); step != (Offset{1, 0}) {
+		t.Errorf("DenseField indexed Follow gave %v, expected (1, 0)", step)
+	}
+	if step := field.Follow(outside); step != (Offset{0, -1}) {
+		t.Errorf("DenseField fallback Follow gave %v, expected (0, -1)", step)
+	}
+
+	// neither path may step onto a wall which the field never reached
+	field.Attract(5, origin)
+	field.weights[2] = -10
+	field.seen[2] = false
+	if step := field.Follow(tiles[1]); step != (Offset{-1, 0}) {
+		t.Errorf("DenseField indexed Follow gave %v, expected (-1, 0)", step)
+	}
+	if step := field.Follow(outside); step != (Offset{}) {
+		t.Errorf("DenseField fallback Follow gave %v, expected (0, 0)", step)
+	}
+}
+
+func TestDenseFieldWeightedAttractAllocs(t *testing.T) {
+	tiles := denseTestGrid(30, 40)
+	field := NewDenseField(NewTileIndex(tiles))
+	goals := []*Tile{tiles[0], tiles[500]}
+	field.WeightedAttract(15, euclidean, goals...)
+	allocs := testing.AllocsPerRun(10, func() {
+		field.WeightedAttract(15, euclidean, goals...)
+	})
+	if allocs != 0 {
+		t.Errorf("DenseField WeightedAttract made %v allocations, expected 0", allocs)
+	}
+}
+
+func BenchmarkAttractiveFieldSparse(b *testing.B) {
+	tiles := denseTestGrid(200, 400)
+	goals := []*Tile{tiles[len(tiles)/2], tiles[len(tiles)/3]}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		AttractiveField(30, goals...)
+	}
+}
+
+func BenchmarkAttractiveFieldDense(b *testing.B) {
+	tiles := denseTestGrid(200, 400)
+	goals := []*Tile{tiles[len(tiles)/2], tiles[len(tiles)/3]}
+	field := NewDenseField(NewTileIndex(tiles))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		field.Attract(30, goals...)
+	}
+}
+
+func BenchmarkFollowSparse(b *testing.B) {
+	tiles := denseTestGrid(200, 400)
+	field := AttractiveField(30, tiles[len(tiles)/2])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		field.Follow(tiles[i%len(tiles)])
+	}
+}
+
+func BenchmarkFollowDense(b *testing.B) {
+	tiles := denseTestGrid(200, 400)
+	field := NewDenseField(NewTileIndex(tiles))
+	field.Attract(30, tiles[len(tiles)/2])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		field.Follow(tiles[i%len(tiles)])
+	}
+}
@@ -15,6 +15,14 @@ func NewDice(src rand.Source) Dice {
 	return Dice{rand.New(src)}
 }
 
+// NewSeededDice creates a new Dice using the same xorshift source as the global
+// Dice, seeded with the given value. The source does not depend on the
+// platform, so passing the same seed to the generators gives the same maps on
+// every machine.
+func NewSeededDice(seed int64) Dice {
+	return NewDice(newXorshift(seed))
+}
+
 // Bool returns true with probability .5 and false otherwise.
 func (d Dice) Bool() bool {
 	return d.Int63()%2 == 1
@@ -227,7 +235,11 @@ type xorshift struct {
 func (x *xorshift) Seed(seed int64) {
 	// Since we use a single 64 bit seed, we use an xorshift64* generator
 	// to get the 1024 bits we need to seed the xorshift1024* generator.
+	// A zero seed would leave the state all zeros, which xorshift never leaves.
 	s := uint64(seed)
+	if s == 0 {
+		s = 0x9E3779B97F4A7C15
+	}
 	for i := 0; i < 16; i++ {
 		s ^= s >> 12
 		s ^= s << 25
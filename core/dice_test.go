@@ -131,6 +131,16 @@ func TestRandSeed(t *testing.T) {
 	}
 }
 
+func TestNewSeededDiceZero(t *testing.T) {
+	d := NewSeededDice(0)
+	for i := 0; i < 10; i++ {
+		if d.Int63() != 0 {
+			return
+		}
+	}
+	t.Errorf("NewSeededDice(0) only produced zeros")
+}
+
 func TestRandBool(t *testing.T) {
 	for _, seed := range seeds {
 		RandSeed(seed)
@@ -14,21 +14,20 @@ const (
 
 type room struct {
 	X, Y, W, H int
-	Tiles      []*Tile
 }
 
-func (r *room) ConnectX(o *room, f MapGenInt) []*Tile {
-	var tiles []*Tile
+func (r *room) ConnectX(d Dice, o *room, plot func(Offset, int) Cell) []Cell {
+	var cells []Cell
 
 	minY := Max(r.Y, o.Y) + 1
 	maxY := Min(r.Y+r.H, o.Y+o.H) - 2
 	var srcY, dstY int
-	if minY < maxY && RandBool() {
-		srcY = RandRange(minY, maxY)
+	if minY < maxY && d.Bool() {
+		srcY = d.Range(minY, maxY)
 		dstY = srcY
 	} else {
-		srcY = RandRange(r.Y+1, r.Y+r.H-2)
-		dstY = RandRange(o.Y+1, o.Y+o.H-2)
+		srcY = d.Range(r.Y+1, r.Y+r.H-2)
+		dstY = d.Range(o.Y+1, o.Y+o.H-2)
 	}
 	srcX, dstX := r.X+r.W/2, o.X+o.W/2
 
@@ -44,82 +43,116 @@ func (r *room) ConnectX(o *room, f MapGenInt) []*Tile {
 		nextX := srcX + Signum(midX-srcX)
 		if InRange(srcX, r.X, r.X+r.W) {
 			if !InRange(nextX, r.X, r.X+r.W) {
-				tiles = append(tiles, f(Offset{srcX, srcY}, TileTypeDoor))
+				cells = append(cells, plot(Offset{srcX, srcY}, TileTypeDoor))
 			}
 		} else {
-			tiles = append(tiles, f(Offset{srcX, srcY}, TileTypeCorridor))
+			cells = append(cells, plot(Offset{srcX, srcY}, TileTypeCorridor))
 		}
 		srcX = nextX
 	}
-	tiles = append(tiles, f(Offset{srcX, srcY}, TileTypeCorridor))
+	cells = append(cells, plot(Offset{srcX, srcY}, TileTypeCorridor))
 
 	for srcY != dstY {
 		srcY += Signum(dstY - srcY)
-		tiles = append(tiles, f(Offset{srcX, srcY}, TileTypeCorridor))
+		cells = append(cells, plot(Offset{srcX, srcY}, TileTypeCorridor))
 	}
 
 	for srcX != dstX {
 		srcX += Signum(dstX - srcX)
 		if InRange(srcX, o.X, o.X+o.W) {
-			tiles = append(tiles, f(Offset{srcX, srcY}, TileTypeDoor))
+			cells = append(cells, plot(Offset{srcX, srcY}, TileTypeDoor))
 			break
 		} else {
-			tiles = append(tiles, f(Offset{srcX, srcY}, TileTypeCorridor))
+			cells = append(cells, plot(Offset{srcX, srcY}, TileTypeCorridor))
 		}
 	}
 
-	return tiles
+	return cells
 }
 
 func (r *room) Transpose() *room {
-	return &room{r.Y, r.X, r.H, r.W, nil}
+	return &room{r.Y, r.X, r.H, r.W}
 }
 
-func (r *room) ConnectY(o *room, f MapGenInt) []*Tile {
-	fTranspose := MapGenInt(func(o Offset, tiletype int) *Tile {
-		return f(Offset{o.Y, o.X}, tiletype)
-	})
-	return r.Transpose().ConnectX(o.Transpose(), fTranspose)
+func (r *room) ConnectY(d Dice, o *room, plot func(Offset, int) Cell) []Cell {
+	plotTranspose := func(o Offset, tiletype int) Cell {
+		return plot(Offset{o.Y, o.X}, tiletype)
+	}
+	return r.Transpose().ConnectX(d, o.Transpose(), plotTranspose)
 }
 
-func (r *room) CreateTiles(f MapGenInt) []*Tile {
-	r.Tiles = NewTileGrid(r.W-2, r.H-2, Offset{r.X + 1, r.Y + 1}, func(o Offset) *Tile {
-		return f(o, TileTypeRoom)
+func (r *room) WriteCells(w TileWriter) {
+	writeGrid(w, r.W-2, r.H-2, Offset{r.X + 1, r.Y + 1}, func(x, y int) int {
+		return TileTypeRoom
 	})
-	return r.Tiles
 }
 
-func (r *room) ConnectDoor(door *Tile) {
-	for _, tile := range r.Tiles {
-		if step := door.Offset.Sub(tile.Offset); step.Chebyshev() == 1 {
-			tile.Adjacent[step] = door
-			door.Adjacent[step.Neg()] = tile
+func (r *room) ConnectDoor(g *cellGraph, door Cell) {
+	for _, step := range cardinal {
+		o := door.Offset.Add(step)
+		if InRange(o.X, r.X+1, r.X+r.W-1) && InRange(o.Y, r.Y+1, r.Y+r.H-1) {
+			g.Link(door, Cell{Offset: o})
 		}
 	}
 }
 
-// Dungeon stub - will eventually generate room and corridor maps.
+// Dungeon creates a room and corridor map as with DungeonWith, making every
+// random choice with the global Dice.
 func Dungeon(numRooms, minRoomSize, maxRoomSize int, f MapGenInt) []*Tile {
-	var tiles []*Tile
+	return DungeonWith(globalDice, numRooms, minRoomSize, maxRoomSize, f)
+}
+
+// DungeonWith creates a room and corridor map, using the MapGenInt to create a
+// Tile for each of the TileType kinds. Every random choice is made with the
+// given Dice, so the same seed always gives the same Dungeon.
+func DungeonWith(d Dice, numRooms, minRoomSize, maxRoomSize int, f MapGenInt) []*Tile {
+	w := NewTileGraphWriter(f)
+	WriteDungeonWith(w, d, numRooms, minRoomSize, maxRoomSize)
+	return w.Tiles()
+}
+
+// WriteDungeon writes a room and corridor map to a TileWriter as with
+// WriteDungeonWith, making every random choice with the global Dice.
+func WriteDungeon(w TileWriter, numRooms, minRoomSize, maxRoomSize int) {
+	WriteDungeonWith(w, globalDice, numRooms, minRoomSize, maxRoomSize)
+}
 
-	maze := abstractBraid(numRooms, .25, 0, 1)
+// WriteDungeonWith writes a room and corridor map to a TileWriter, as with
+// Dungeon. Each cell is given one of the TileType kinds, and every random
+// choice is made with the given Dice.
+func WriteDungeonWith(w TileWriter, d Dice, numRooms, minRoomSize, maxRoomSize int) {
+	g := dungeonGraph(d, numRooms, minRoomSize, maxRoomSize)
+	g.addWalls(TileTypeWall)
+	g.flush(w)
+}
+
+// dungeonGraph builds the rooms and corridors of a Dungeon in a cellGraph,
+// connected diagonally but not yet surrounded by walls.
+func dungeonGraph(d Dice, numRooms, minRoomSize, maxRoomSize int) *cellGraph {
+	g := newCellGraph()
+	plot := func(o Offset, tiletype int) Cell {
+		c := Cell{Offset: o}
+		g.SetCell(c, tiletype)
+		return c
+	}
+
+	maze := abstractBraid(d, numRooms, .25, 0, 1)
+	nodes := maze.SortedNodes()
 	rooms := make(map[*mazenode]*room)
 	gridSize := maxRoomSize + minRoomSize
 
 	// create rooms
-	for _, nodes := range maze.Nodes {
-		for _, node := range nodes {
-			w := RandRange(minRoomSize, maxRoomSize)
-			h := RandRange(minRoomSize, maxRoomSize)
-			x := RandRange(gridSize*node.Pos.X, gridSize*(node.Pos.X+1)-w-1)
-			y := RandRange(gridSize*node.Pos.Y, gridSize*(node.Pos.Y+1)-h-1)
-			rooms[node] = &room{x, y, w, h, nil}
-		}
+	for _, node := range nodes {
+		w := d.Range(minRoomSize, maxRoomSize)
+		h := d.Range(minRoomSize, maxRoomSize)
+		x := d.Range(gridSize*node.Pos.X, gridSize*(node.Pos.X+1)-w-1)
+		y := d.Range(gridSize*node.Pos.Y, gridSize*(node.Pos.Y+1)-h-1)
+		rooms[node] = &room{x, y, w, h}
 	}
 
-	// create room tiles
-	for _, room := range rooms {
-		tiles = append(tiles, room.CreateTiles(f)...)
+	// create room cells
+	for _, node := range nodes {
+		rooms[node].WriteCells(g)
 	}
 
 	// create corridors
@@ -136,7 +169,8 @@ func Dungeon(numRooms, minRoomSize, maxRoomSize int, f MapGenInt) []*Tile {
 		}
 		closed[curr] = struct{}{}
 
-		for step, adj := range curr.Edges {
+		for _, step := range curr.SortedEdges() {
+			adj := curr.Edges[step]
 			if _, done := closed[adj]; done {
 				continue
 			}
@@ -144,32 +178,22 @@ func Dungeon(numRooms, minRoomSize, maxRoomSize int, f MapGenInt) []*Tile {
 				frontier = append(frontier, adj)
 			}
 
-			var corridor []*Tile
+			var corridor []Cell
 			currRoom, adjRoom := rooms[curr], rooms[adj]
 			if step.X != 0 {
-				corridor = currRoom.ConnectX(adjRoom, f)
+				corridor = currRoom.ConnectX(d, adjRoom, plot)
 			} else {
-				corridor = currRoom.ConnectY(adjRoom, f)
+				corridor = currRoom.ConnectY(d, adjRoom, plot)
 			}
 
-			currRoom.ConnectDoor(corridor[0])
-			adjRoom.ConnectDoor(corridor[len(corridor)-1])
+			currRoom.ConnectDoor(g, corridor[0])
+			adjRoom.ConnectDoor(g, corridor[len(corridor)-1])
 			for i := 0; i < len(corridor)-1; i++ {
-				j := i + 1
-				step := corridor[j].Offset.Sub(corridor[i].Offset)
-				corridor[i].Adjacent[step] = corridor[j]
-				corridor[j].Adjacent[step.Neg()] = corridor[i]
+				g.Link(corridor[i], corridor[i+1])
 			}
-
-			tiles = append(tiles, corridor...)
 		}
 	}
 
-	connectDiagonals(tiles[0])
-	walls := addWalls(tiles[0], func(o Offset) *Tile {
-		return f(o, TileTypeWall)
-	})
-	tiles = append(tiles, walls...)
-
-	return tiles
+	g.connectDiagonals()
+	return g
 }
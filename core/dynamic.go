@@ -0,0 +1,195 @@
+package core
+
+// DynamicField is an attractive Field which can be updated incrementally. Goals
+// can be added and removed, and Tiles can change passability, with only the
+// affected region of the Field being repaired. This allows a single Field to be
+// shared and continuously maintained, such as by a tribe chasing a moving herd,
+// instead of creating a new AttractiveField every turn. As with
+// AttractiveField, the DynamicField spreads through Stairs as well as Adjacent
+// Tiles, so its weights are always identical to the weights of an
+// AttractiveField with the same radius and goals.
+type DynamicField struct {
+	radius int
+	goals  map[*Tile]struct{}
+	dist   map[*Tile]int
+}
+
+// NewDynamicField creates a DynamicField which pulls towards the given goals.
+func NewDynamicField(radius int, goals ...*Tile) *DynamicField {
+	f := &DynamicField{radius, make(map[*Tile]struct{}), make(map[*Tile]int)}
+	for _, goal := range goals {
+		f.AddGoal(goal)
+	}
+	return f
+}
+
+// Weight implements WeightedField for DynamicField.
+func (f *DynamicField) Weight(t *Tile) float64 {
+	if dist, ok := f.dist[t]; ok {
+		return float64(dist - f.radius)
+	}
+	return 0
+}
+
+// reached implements reachField for DynamicField.
+func (f *DynamicField) reached(t *Tile) bool {
+	_, ok := f.dist[t]
+	return ok
+}
+
+// Follow returns an Offset from the given Tile which will lead to the
+// neighboring tile which has a lower weight. Goal weights are negative
+// so that the default value of 0 is neutral. Only Tiles reached by the field
+// are considered, as with DenseField.
+func (f *DynamicField) Follow(t *Tile) Offset {
+	minWeight, minOffset := f.Weight(t), Offset{}
+
+	for offset, adj := range t.Adjacent {
+		if weight := f.Weight(adj); weight < minWeight && f.reached(adj) {
+			minWeight = weight
+			minOffset = offset
+		}
+	}
+
+	return minOffset
+}
+
+// AddGoal adds a goal to the DynamicField. Only the region which is closer to
+// the new goal than any other goal is updated.
+func (f *DynamicField) AddGoal(goal *Tile) {
+	f.goals[goal] = struct{}{}
+	f.dist[goal] = 0
+	f.propagate([]*Tile{goal})
+}
+
+// RemoveGoal removes a goal from the DynamicField. Only the region which was
+// closest to the removed goal is updated.
+func (f *DynamicField) RemoveGoal(goal *Tile) {
+	if _, ok := f.goals[goal]; !ok {
+		return
+	}
+	delete(f.goals, goal)
+	f.repair(goal)
+}
+
+// MoveGoal replaces one goal with another, such as when the goal is the
+// position of a moving Entity.
+func (f *DynamicField) MoveGoal(from, to *Tile) {
+	f.RemoveGoal(from)
+	f.AddGoal(to)
+}
+
+// UpdateTile informs the DynamicField that the passability of a Tile has
+// changed, such as a door opening or closing, so that the Field can be
+// repaired around that Tile.
+func (f *DynamicField) UpdateTile(t *Tile) {
+	if _, goal := f.goals[t]; goal {
+		return
+	}
+
+	if t.Pass {
+		// the Tile may now provide a shortcut, so compute its distance from
+		// its neighbors, and spread any improvement outwards.
+		best, found := 0, false
+		consider := func(adj *Tile) {
+			if dist, ok := f.dist[adj]; ok && (!found || dist+1 < best) {
+				best, found = dist+1, true
+			}
+		}
+		for _, adj := range t.Adjacent {
+			consider(adj)
+		}
+		for _, adj := range t.Stairs {
+			consider(adj)
+		}
+		if found && best <= f.radius {
+			if dist, ok := f.dist[t]; !ok || best < dist {
+				f.dist[t] = best
+				f.propagate([]*Tile{t})
+			}
+		}
+	} else if _, ok := f.dist[t]; ok {
+		f.repair(t)
+	}
+}
+
+// propagate spreads decreased distances outwards from the given Tiles.
+func (f *DynamicField) propagate(queue []*Tile) {
+	for len(queue) > 0 {
+		// pop the next Tile off the queue
+		curr := queue[0]
+		queue = queue[1:]
+
+		// if we've reached edge field, stop expanding the field
+		dist := f.dist[curr] + 1
+		if dist > f.radius {
+			continue
+		}
+
+		// expand the frontier with any neighbor whose distance decreased
+		expand := func(adj *Tile) {
+			if prev, seen := f.dist[adj]; adj.Pass && (!seen || dist < prev) {
+				f.dist[adj] = dist
+				queue = append(queue, adj)
+			}
+		}
+		for _, adj := range curr.Adjacent {
+			expand(adj)
+		}
+		for _, adj := range curr.Stairs {
+			expand(adj)
+		}
+	}
+}
+
+// repair recomputes the distances of every Tile which may have depended on the
+// distance of the given Tile, which has either stopped being a goal or stopped
+// being passable.
+func (f *DynamicField) repair(t *Tile) {
+	// find every Tile which could have gotten its distance through t, meaning
+	// each Tile which is exactly one step further than the Tile before it.
+	affected := map[*Tile]struct{}{t: {}}
+	frontier := []*Tile{t}
+	for len(frontier) > 0 {
+		curr := frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+
+		expand := func(adj *Tile) {
+			_, seen := affected[adj]
+			_, goal := f.goals[adj]
+			if dist, ok := f.dist[adj]; ok && !seen && !goal && dist == f.dist[curr]+1 {
+				affected[adj] = struct{}{}
+				frontier = append(frontier, adj)
+			}
+		}
+		for _, adj := range curr.Adjacent {
+			expand(adj)
+		}
+		for _, adj := range curr.Stairs {
+			expand(adj)
+		}
+	}
+
+	// clear the affected region, and then refill it from its boundary
+	for tile := range affected {
+		delete(f.dist, tile)
+	}
+	var boundary []*Tile
+	for tile := range affected {
+		for _, adj := range tile.Adjacent {
+			if _, ok := f.dist[adj]; ok {
+				boundary = append(boundary, adj)
+			}
+		}
+		for _, adj := range tile.Stairs {
+			if _, ok := f.dist[adj]; ok {
+				boundary = append(boundary, adj)
+			}
+		}
+	}
+	if _, goal := f.goals[t]; goal {
+		f.dist[t] = 0
+		boundary = append(boundary, t)
+	}
+	f.propagate(boundary)
+}
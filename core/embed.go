@@ -0,0 +1,204 @@
+package core
+
+import (
+	"sort"
+)
+
+// TileBounds returns the minimum and maximum Offsets of a set of Tile, which
+// gives the size of a submap when searching for a site with FindSite.
+func TileBounds(tiles []*Tile) (min, max Offset) {
+	min, max = tiles[0].Offset, tiles[0].Offset
+	for _, tile := range tiles {
+		min.X, min.Y = Min(min.X, tile.Offset.X), Min(min.Y, tile.Offset.Y)
+		max.X, max.Y = Max(max.X, tile.Offset.X), Max(max.Y, tile.Offset.Y)
+	}
+	return min, max
+}
+
+// levelIndex maps the Offset of each Tile on the given Level to the Tile.
+func levelIndex(tiles []*Tile, level int) map[Offset]*Tile {
+	index := make(map[Offset]*Tile, len(tiles))
+	for _, tile := range tiles {
+		if tile.Level == level {
+			index[tile.Offset] = tile
+		}
+	}
+	return index
+}
+
+// FindSite searches for a place in the overworld to put a submap which is cols
+// by rows Tiles. The accept function is given the overworld Tiles covered by a
+// candidate site, and decides whether the site is suitable, such as requiring
+// that a dungeon be dug into a hillside. Candidate sites are tried in a random
+// order, and the Offset of the top-left corner of the first accepted site is
+// returned. If no site is accepted, ok will be false. FindSite uses the global
+// Dice.
+func FindSite(overworld []*Tile, cols, rows int, accept func([]*Tile) bool) (site Offset, ok bool) {
+	return FindSiteWith(globalDice, overworld, cols, rows, accept)
+}
+
+// FindSiteWith searches for a site for a submap as with FindSite, but orders
+// the candidate sites using the given Dice.
+func FindSiteWith(d Dice, overworld []*Tile, cols, rows int, accept func([]*Tile) bool) (site Offset, ok bool) {
+	if len(overworld) == 0 {
+		return Offset{}, false
+	}
+	index := levelIndex(overworld, overworld[0].Level)
+
+	order := make([]*Tile, len(overworld))
+	copy(order, overworld)
+	for i := len(order) - 1; i > 0; i-- {
+		j := d.Intn(i + 1)
+		order[i], order[j] = order[j], order[i]
+	}
+
+	footprint := make([]*Tile, 0, cols*rows)
+	for _, corner := range order {
+		footprint = footprint[:0]
+		for x := 0; x < cols; x++ {
+			for y := 0; y < rows; y++ {
+				if tile, ok := index[corner.Offset.Add(Offset{x, y})]; ok && tile.Level == corner.Level {
+					footprint = append(footprint, tile)
+				}
+			}
+		}
+		if len(footprint) == cols*rows && accept(footprint) {
+			return corner.Offset, true
+		}
+	}
+
+	return Offset{}, false
+}
+
+// Embed splices a submap, such as a maze or a Dungeon, into an overworld. The
+// submap is translated so that its top-left corner is at the given site, and
+// then replaces the overworld Tiles it covers, with the Adjacent maps of the
+// Tiles along the seam wired in both directions. Entrances are then opened in
+// the outer walls of the submap. The entrance function is given each wall of
+// the submap which separates a passable submap Tile from a passable overworld
+// Tile, in order of Offset, and decides whether to open it, in which case the
+// wall takes the Face, Pass and Lite of a Tile created by the door MapGen. If
+// no entrance is opened, ErrNoEntrance is returned and neither map is changed.
+// Otherwise, the Tiles of the combined map are returned. Only Tiles on the base
+// Level of each map are spliced, so any Tiles raised by weaving remain above
+// the seam.
+func Embed(overworld, submap []*Tile, site Offset, entrance func(*Tile) bool, door MapGen) ([]*Tile, error) {
+	if len(overworld) == 0 || len(submap) == 0 {
+		return nil, ErrNoEntrance
+	}
+
+	min, _ := TileBounds(submap)
+	delta := site.Sub(min)
+	outerLevel, innerLevel := overworld[0].Level, submap[0].Level
+	for _, tile := range submap {
+		innerLevel = Min(innerLevel, tile.Level)
+	}
+	outer := levelIndex(overworld, outerLevel)
+	inner := make(map[Offset]*Tile)
+	for _, tile := range submap {
+		if tile.Level == innerLevel {
+			inner[tile.Offset.Add(delta)] = tile
+		}
+	}
+
+	// outside returns the overworld Tile at an Offset which remains after the
+	// submap is spliced in, if any.
+	outside := func(o Offset) (*Tile, bool) {
+		if _, covered := inner[o]; covered {
+			return nil, false
+		}
+		tile, ok := outer[o]
+		return tile, ok
+	}
+
+	// find the submap walls which could join the two maps, and choose which
+	// should be opened as entrances. The walls are visited in a fixed order,
+	// so that an entrance function using Dice opens the same doors each time.
+	offsets := make([]Offset, 0, len(inner))
+	for o := range inner {
+		offsets = append(offsets, o)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].X != offsets[j].X {
+			return offsets[i].X < offsets[j].X
+		}
+		return offsets[i].Y < offsets[j].Y
+	})
+	var doors []*Tile
+	for _, o := range offsets {
+		tile := inner[o]
+		if tile.Pass {
+			continue
+		}
+		in, out := false, false
+		for _, step := range orthogonal {
+			if adj, ok := inner[o.Add(step)]; ok && adj.Pass {
+				in = true
+			}
+			if adj, ok := outside(o.Add(step)); ok && adj.Pass {
+				out = true
+			}
+		}
+		if in && out && entrance(tile) {
+			doors = append(doors, tile)
+		}
+	}
+	if len(doors) == 0 {
+		return nil, ErrNoEntrance
+	}
+
+	// translate the submap so that it sits in the overworld
+	for _, tile := range submap {
+		tile.Offset = tile.Offset.Add(delta)
+		tile.Level += outerLevel - innerLevel
+	}
+
+	// wire the seam between the submap and the remaining overworld
+	for _, o := range offsets {
+		tile := inner[o]
+		for _, step := range cardinal {
+			if adj, ok := outside(o.Add(step)); ok {
+				tile.Adjacent[step] = adj
+				adj.Adjacent[step.Neg()] = tile
+			}
+		}
+	}
+
+	// open the entrances, making sure each is connected on every side
+	for _, tile := range doors {
+		open := door(tile.Offset)
+		tile.Face, tile.Pass, tile.Lite = open.Face, open.Pass, open.Lite
+		for _, step := range cardinal {
+			if adj, ok := inner[tile.Offset.Add(step)]; ok {
+				tile.Adjacent[step] = adj
+				adj.Adjacent[step.Neg()] = tile
+			}
+		}
+	}
+
+	// combine the maps, dropping the overworld Tiles which were replaced
+	combined := make([]*Tile, 0, len(overworld)+len(submap))
+	for _, tile := range overworld {
+		if _, covered := inner[tile.Offset]; !covered || tile.Level != outerLevel {
+			combined = append(combined, tile)
+		}
+	}
+	combined = append(combined, submap...)
+
+	return combined, nil
+}
+
+// EmbedBelow places a submap, such as a maze or a Dungeon, on a new Level
+// beneath the site Tile, rather than splicing it into the same Level. The
+// submap is translated so that the given entrance Tile of the submap lies
+// directly below the site, and the two are linked with Stairs. Any Levels used
+// by the submap, such as those raised by weaving, are shifted along with it.
+func EmbedBelow(site *Tile, submap []*Tile, entrance *Tile) error {
+	delta := site.Offset.Sub(entrance.Offset)
+	levels := site.Level + 1 - entrance.Level
+	for _, tile := range submap {
+		tile.Offset = tile.Offset.Add(delta)
+		tile.Level += levels
+	}
+	return LinkStairs(site, entrance)
+}
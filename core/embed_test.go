@@ -0,0 +1,150 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// embedTestRoom creates a 5x5 room surrounded by walls, with its own origin.
+func embedTestRoom() []*Tile {
+	return NewTileGrid(5, 5, Offset{}, func(o Offset) *Tile {
+		t := NewTile(o)
+		if o.X == 0 || o.Y == 0 || o.X == 4 || o.Y == 4 {
+			t.Pass = false
+			t.Face = Glyph{'#', ColorWhite}
+		}
+		return t
+	})
+}
+
+func TestEmbed(t *testing.T) {
+	overworld := NewTileGrid(20, 20, Offset{}, NewTile)
+	room := embedTestRoom()
+	door := func(o Offset) *Tile {
+		t := NewTile(o)
+		t.Face.Ch = '+'
+		return t
+	}
+
+	// rejecting every entrance leaves both maps unchanged
+	if _, err := Embed(overworld, room, Offset{5, 5}, func(*Tile) bool { return false }, door); err != ErrNoEntrance {
+		t.Errorf("Embed gave %v, expected ErrNoEntrance", err)
+	}
+	if room[0].Offset != (Offset{}) {
+		t.Errorf("Embed translated the submap on error")
+	}
+
+	combined, err := Embed(overworld, room, Offset{5, 5}, func(*Tile) bool { return true }, door)
+	if err != nil {
+		t.Fatalf("Embed gave error %v", err)
+	}
+	if len(combined) != 400 {
+		t.Errorf("Embed gave %d Tiles, expected 400", len(combined))
+	}
+
+	// every Tile should be unique by Offset, and wired in both directions
+	seen := make(map[Offset]*Tile)
+	doors := 0
+	for _, tile := range combined {
+		if _, dup := seen[tile.Offset]; dup {
+			t.Errorf("Embed left two Tiles at %v", tile.Offset)
+		}
+		seen[tile.Offset] = tile
+		if tile.Face.Ch == '+' {
+			doors++
+		}
+	}
+	for _, tile := range combined {
+		for step, adj := range tile.Adjacent {
+			if seen[tile.Offset.Add(step)] != adj {
+				t.Errorf("Embed left stale adjacency at %v", tile.Offset)
+			}
+		}
+	}
+	if doors != 12 {
+		t.Errorf("Embed opened %d entrances, expected 12", doors)
+	}
+	if NewRegions(combined, nil).Count() != 1 {
+		t.Errorf("Embed did not connect the submap to the overworld")
+	}
+}
+
+func TestEmbedEntranceOrder(t *testing.T) {
+	// a counting entrance function opens every third candidate wall, so the
+	// same walls are only opened if the candidates are always in the same order
+	var expected []Offset
+	for i := 0; i < 20; i++ {
+		count := 0
+		entrance := func(*Tile) bool {
+			count++
+			return count%3 == 0
+		}
+		combined, err := Embed(NewTileGrid(20, 20, Offset{}, NewTile), embedTestRoom(), Offset{5, 5}, entrance, NewTile)
+		if err != nil {
+			t.Fatalf("Embed gave error %v", err)
+		}
+
+		var opened []Offset
+		for _, tile := range combined {
+			if tile.Pass && tile.Face.Ch == '#' {
+				t.Fatalf("Embed left a passable wall")
+			}
+			if tile.Pass && tile.Offset.X >= 5 && tile.Offset.Y >= 5 && tile.Offset.X <= 9 && tile.Offset.Y <= 9 && (tile.Offset.X == 5 || tile.Offset.Y == 5 || tile.Offset.X == 9 || tile.Offset.Y == 9) {
+				opened = append(opened, tile.Offset)
+			}
+		}
+		if i == 0 {
+			expected = opened
+		} else if !reflect.DeepEqual(opened, expected) {
+			t.Errorf("Embed opened %v, expected %v", opened, expected)
+		}
+	}
+	if len(expected) != 4 {
+		t.Errorf("Embed opened %d entrances, expected 4", len(expected))
+	}
+}
+
+func TestEmbedBelow(t *testing.T) {
+	site := NewTile(Offset{10, 10})
+	room := embedTestRoom()
+	entrance := room[12]
+
+	if err := EmbedBelow(site, room, entrance); err != nil {
+		t.Fatalf("EmbedBelow gave error %v", err)
+	}
+	if entrance.Offset != site.Offset || entrance.Level != 1 {
+		t.Errorf("EmbedBelow placed entrance at %v on Level %d", entrance.Offset, entrance.Level)
+	}
+	if site.Stairs[1] != entrance || entrance.Stairs[-1] != site {
+		t.Errorf("EmbedBelow did not link Stairs")
+	}
+	if room[0].Offset != (Offset{8, 8}) || room[0].Level != 1 {
+		t.Errorf("EmbedBelow did not translate the submap")
+	}
+}
+
+func TestFindSite(t *testing.T) {
+	overworld := NewTileGrid(10, 10, Offset{}, func(o Offset) *Tile {
+		t := NewTile(o)
+		if o.X < 7 {
+			t.Face.Ch = '~'
+		}
+		return t
+	})
+	dry := func(footprint []*Tile) bool {
+		for _, tile := range footprint {
+			if tile.Face.Ch == '~' {
+				return false
+			}
+		}
+		return true
+	}
+
+	site, ok := FindSite(overworld, 3, 3, dry)
+	if !ok || site.X != 7 || site.Y < 0 || site.Y > 7 {
+		t.Errorf("FindSite gave %v, %v", site, ok)
+	}
+	if _, ok := FindSite(overworld, 4, 4, dry); ok {
+		t.Errorf("FindSite found a site which does not fit")
+	}
+}
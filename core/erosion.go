@@ -0,0 +1,230 @@
+package core
+
+import (
+	"math"
+)
+
+// HydraulicErosion specifies droplet based hydraulic erosion. Each of the
+// Droplets starts at a random location and rolls downhill for up to Lifetime
+// steps, with Inertia controlling how much it keeps its previous direction.
+// A droplet can carry sediment in proportion to its speed, water and the slope
+// (never less than MinSlope), scaled by Capacity. When carrying less than it
+// can, the droplet picks up Erosion of the difference from the ground, and
+// when carrying more, or when moving uphill, it drops Deposition of the excess.
+// Droplets speed up with Gravity as they descend, and lose Evaporation of their
+// water with each step.
+type HydraulicErosion struct {
+	Droplets    int
+	Lifetime    int
+	Inertia     float64
+	Capacity    float64
+	MinSlope    float64
+	Erosion     float64
+	Deposition  float64
+	Gravity     float64
+	Evaporation float64
+}
+
+// DefaultHydraulicErosion gives moderate valley carving for a normalized
+// Heightmap of a few hundred cells across.
+var DefaultHydraulicErosion = HydraulicErosion{
+	Droplets:    50000,
+	Lifetime:    30,
+	Inertia:     .05,
+	Capacity:    4,
+	MinSlope:    .001,
+	Erosion:     .3,
+	Deposition:  .3,
+	Gravity:     4,
+	Evaporation: .02,
+}
+
+// sampleGradient computes the bilinearly interpolated height at a point, along
+// with the gradient of the height. The point must be in bounds, wrapping the
+// x-axis if WrapX is true.
+func (h *Heightmap) sampleGradient(x, y float64) (height, gx, gy float64) {
+	cx, cy := int(x), int(y)
+	fx, fy := x-float64(cx), y-float64(cy)
+	nx := cx + 1
+	if h.WrapX {
+		nx = Mod(nx, h.cols)
+	}
+
+	nw, ne := h.buf[cx][cy], h.buf[nx][cy]
+	sw, se := h.buf[cx][cy+1], h.buf[nx][cy+1]
+	gx = (ne-nw)*(1-fy) + (se-sw)*fy
+	gy = (sw-nw)*(1-fx) + (se-ne)*fx
+	height = nw*(1-fx)*(1-fy) + ne*fx*(1-fy) + sw*(1-fx)*fy + se*fx*fy
+	return height, gx, gy
+}
+
+// spread adds an amount to the four cells around a point, weighted by their
+// bilinear interpolation weights.
+func (h *Heightmap) spread(x, y, amount float64) {
+	cx, cy := int(x), int(y)
+	fx, fy := x-float64(cx), y-float64(cy)
+	nx := cx + 1
+	if h.WrapX {
+		nx = Mod(nx, h.cols)
+	}
+
+	h.buf[cx][cy] += amount * (1 - fx) * (1 - fy)
+	h.buf[nx][cy] += amount * fx * (1 - fy)
+	h.buf[cx][cy+1] += amount * (1 - fx) * fy
+	h.buf[nx][cy+1] += amount * fx * fy
+}
+
+// inGradientBounds checks whether a point can be sampled by sampleGradient,
+// wrapping the x value if WrapX is true.
+func (h *Heightmap) inGradientBounds(x, y float64) (float64, float64, bool) {
+	if h.WrapX {
+		x = math.Mod(x, float64(h.cols))
+		if x < 0 {
+			x += float64(h.cols)
+		}
+		if x >= float64(h.cols) {
+			x = 0
+		}
+	} else if x < 0 || x >= float64(h.cols-1) {
+		return x, y, false
+	}
+	return x, y, y >= 0 && y < float64(h.rows-1)
+}
+
+// ErodeHydraulic weathers the Heightmap as with ErodeHydraulicWith, using the
+// global Dice.
+func (h *Heightmap) ErodeHydraulic(e HydraulicErosion) {
+	h.ErodeHydraulicWith(globalDice, e)
+}
+
+// ErodeHydraulicWith weathers the Heightmap with droplet based hydraulic
+// erosion, which carves valleys into slopes and deposits sediment in basins.
+// The given Dice chooses the starting location of each droplet, so the result
+// is deterministic under a seed. Droplets wrap around the x-axis if WrapX is
+// true, and otherwise stop when they leave the map, along with any sediment
+// they were carrying.
+func (h *Heightmap) ErodeHydraulicWith(d Dice, e HydraulicErosion) {
+	if h.cols < 2 || h.rows < 2 {
+		return
+	}
+
+	maxX := float64(h.cols - 1)
+	if h.WrapX {
+		maxX = float64(h.cols)
+	}
+
+	for i := 0; i < e.Droplets; i++ {
+		x, y := d.Float64()*maxX, d.Float64()*float64(h.rows-1)
+		dx, dy := 0.0, 0.0
+		speed, water, sediment := 1.0, 1.0, 0.0
+
+		for step := 0; step < e.Lifetime; step++ {
+			// roll downhill, keeping some of the previous direction
+			height, gx, gy := h.sampleGradient(x, y)
+			dx = dx*e.Inertia - gx*(1-e.Inertia)
+			dy = dy*e.Inertia - gy*(1-e.Inertia)
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				break
+			}
+			dx, dy = dx/length, dy/length
+
+			nx, ny, ok := h.inGradientBounds(x+dx, y+dy)
+			if !ok {
+				break
+			}
+			next, _, _ := h.sampleGradient(nx, ny)
+			delta := next - height
+
+			// either deposit the excess sediment, or erode more of the ground
+			capacity := math.Max(-delta, e.MinSlope) * speed * water * e.Capacity
+			if delta > 0 || sediment > capacity {
+				amount := (sediment - capacity) * e.Deposition
+				if delta > 0 {
+					amount = math.Min(delta, sediment)
+				}
+				sediment -= amount
+				h.spread(x, y, amount)
+			} else {
+				amount := math.Min((capacity-sediment)*e.Erosion, -delta)
+				sediment += amount
+				h.spread(x, y, -amount)
+			}
+
+			speed = math.Sqrt(math.Max(0, speed*speed-delta*e.Gravity))
+			water *= 1 - e.Evaporation
+			x, y = nx, ny
+		}
+	}
+}
+
+// ThermalErosion specifies thermal erosion, in which material slumps down any
+// slope steeper than the Talus, meaning any height difference between
+// neighboring cells greater than Talus. On each of the Iterations, Strength
+// of the excess is moved downhill, so Strength should be in (0, .5].
+type ThermalErosion struct {
+	Iterations int
+	Talus      float64
+	Strength   float64
+}
+
+// DefaultThermalErosion slumps the steepest cliffs of a normalized Heightmap.
+var DefaultThermalErosion = ThermalErosion{Iterations: 20, Talus: .01, Strength: .5}
+
+// ErodeThermal weathers the Heightmap with thermal erosion, which slumps steep
+// slopes until they reach the angle of repose. Each iteration moves material
+// simultaneously for every cell, so the result is deterministic and does not
+// depend on the order in which cells are visited. The total height is
+// conserved. Material slumps around the x-axis if WrapX is true.
+func (h *Heightmap) ErodeThermal(e ThermalErosion) {
+	change := make([][]float64, h.cols)
+	for x := range change {
+		change[x] = make([]float64, h.rows)
+	}
+
+	for i := 0; i < e.Iterations; i++ {
+		for x := 0; x < h.cols; x++ {
+			for y := 0; y < h.rows; y++ {
+				change[x][y] = 0
+			}
+		}
+
+		for x := 0; x < h.cols; x++ {
+			for y := 0; y < h.rows; y++ {
+				// find the neighbors which are too far below this cell
+				var below [8]Offset
+				var excess [8]float64
+				n, total, steepest := 0, 0.0, 0.0
+				for _, step := range cardinal {
+					nx, ny := x+step.X, y+step.Y
+					if h.WrapX {
+						nx = Mod(nx, h.cols)
+					}
+					if !InBounds(nx, ny, h.cols, h.rows) {
+						continue
+					}
+					if diff := h.buf[x][y] - h.buf[nx][ny]; diff > e.Talus {
+						below[n], excess[n] = Offset{nx, ny}, diff
+						total += diff
+						steepest = math.Max(steepest, diff)
+						n++
+					}
+				}
+
+				// slump the excess, shared in proportion to steepness
+				moved := e.Strength * (steepest - e.Talus)
+				for j := 0; j < n; j++ {
+					share := moved * excess[j] / total
+					change[below[j].X][below[j].Y] += share
+					change[x][y] -= share
+				}
+			}
+		}
+
+		for x := 0; x < h.cols; x++ {
+			for y := 0; y < h.rows; y++ {
+				h.buf[x][y] += change[x][y]
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// sumHeightmap totals the values of a Heightmap.
+func sumHeightmap(h *Heightmap) float64 {
+	total := 0.0
+	for x := 0; x < h.cols; x++ {
+		for y := 0; y < h.rows; y++ {
+			total += h.Read(x, y)
+		}
+	}
+	return total
+}
+
+func TestErodeHydraulic(t *testing.T) {
+	a, b := NewHeightmap(40, 20), NewHeightmap(40, 20)
+	a.GenerateFractalWith(NewDice(newXorshift(1)), DefaultFractal)
+	b.GenerateFractalWith(NewDice(newXorshift(1)), DefaultFractal)
+	before := NewHeightmap(40, 20)
+	before.GenerateFractalWith(NewDice(newXorshift(1)), DefaultFractal)
+
+	e := DefaultHydraulicErosion
+	e.Droplets = 2000
+	a.ErodeHydraulicWith(NewDice(newXorshift(2)), e)
+	b.ErodeHydraulicWith(NewDice(newXorshift(2)), e)
+
+	lowered, raised := false, false
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			if a.Read(x, y) != b.Read(x, y) {
+				t.Fatalf("ErodeHydraulic was not deterministic at %d, %d", x, y)
+			}
+			if v := a.Read(x, y); math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Fatalf("ErodeHydraulic gave %v at %d, %d", v, x, y)
+			}
+			lowered = lowered || a.Read(x, y) < before.Read(x, y)
+			raised = raised || a.Read(x, y) > before.Read(x, y)
+		}
+	}
+	if !lowered || !raised {
+		t.Errorf("ErodeHydraulic did not both erode and deposit")
+	}
+
+	// sediment is only lost when droplets leave the map, so erosion can only
+	// remove height
+	if sumHeightmap(a) > sumHeightmap(before)+1e-9 {
+		t.Errorf("ErodeHydraulic added height")
+	}
+}
+
+func TestErodeHydraulicWrap(t *testing.T) {
+	// a slope which falls to the left across the seam, ending in a basin at
+	// the foot of a cliff in the middle of the map
+	h := NewHeightmap(20, 10)
+	h.WrapX = true
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 10; y++ {
+			h.buf[x][y] = float64(Mod(x+10, 20)) / 20
+		}
+	}
+	before := sumHeightmap(h)
+
+	e := DefaultHydraulicErosion
+	e.Droplets = 500
+	h.ErodeHydraulicWith(NewDice(newXorshift(1)), e)
+
+	if after := sumHeightmap(h); after > before+1e-9 {
+		t.Errorf("ErodeHydraulic added height: %v > %v", after, before)
+	}
+	if h.Read(0, 5) >= .5 || h.Read(19, 5) >= .45 {
+		t.Errorf("ErodeHydraulic did not erode the seam")
+	}
+	if h.Read(10, 5) <= 0 {
+		t.Errorf("ErodeHydraulic did not deposit in the basin")
+	}
+}
+
+func TestErodeThermal(t *testing.T) {
+	cases := []struct {
+		wrap bool
+		seam float64
+	}{
+		{false, 0},
+		{true, 1},
+	}
+	for _, c := range cases {
+		h := NewHeightmap(10, 10)
+		h.WrapX = c.wrap
+		h.buf[0][5] = 1
+		h.ErodeThermal(ThermalErosion{Iterations: 1, Talus: .1, Strength: .5})
+
+		if v := h.Read(0, 5); math.Abs(v-.55) > 1e-9 {
+			t.Errorf("ErodeThermal left peak at %v, expected .55", v)
+		}
+		if math.Abs(sumHeightmap(h)-1) > 1e-9 {
+			t.Errorf("ErodeThermal did not conserve height: %v", sumHeightmap(h))
+		}
+		if v := h.Read(9, 5); (v > 0) != (c.seam > 0) {
+			t.Errorf("ErodeThermal with WrapX %v slumped %v across the seam", c.wrap, v)
+		}
+	}
+
+	// enough iterations should bring every slope within the talus
+	h := NewHeightmap(20, 20)
+	h.GenerateFractalWith(NewDice(newXorshift(1)), Fractal{Octaves: 1, Frequency: 8, Ridged: true})
+	h.ErodeThermal(ThermalErosion{Iterations: 500, Talus: .05, Strength: .5})
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			for _, step := range cardinal {
+				nx, ny := x+step.X, y+step.Y
+				if InBounds(nx, ny, 20, 20) && h.Read(x, y)-h.Read(nx, ny) > .06 {
+					t.Fatalf("ErodeThermal left a slope of %v at %d, %d", h.Read(x, y)-h.Read(nx, ny), x, y)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkErodeHydraulic(b *testing.B) {
+	h := NewHeightmap(200, 100)
+	h.GenerateFractalWith(NewDice(newXorshift(1)), DefaultFractal)
+	d := NewDice(newXorshift(1))
+	for i := 0; i < b.N; i++ {
+		h.ErodeHydraulicWith(d, DefaultHydraulicErosion)
+	}
+}
@@ -9,14 +9,64 @@ type Field interface {
 	Follow(*Tile) Offset
 }
 
+// WeightedField is a Field whose weights can be queried, which allows it to be
+// combined with other Field. Lower weights are more attractive, and a weight
+// of 0 is neutral.
+type WeightedField interface {
+	Field
+	Weight(*Tile) float64
+}
+
+// FieldWeight returns the weight of a Tile in the given Field. If the Field is
+// not a WeightedField, then the weight is always 0.
+func FieldWeight(f Field, t *Tile) float64 {
+	if f, ok := f.(WeightedField); ok {
+		return f.Weight(t)
+	}
+	return 0
+}
+
+// reachField is implemented by Field which know the Tiles their computation
+// reached, so that combined fields, such as SumField and NegField, only lead
+// onto those Tiles. Any other Tile, such as a wall bordering the field, keeps
+// the neutral weight of 0, which would otherwise attract an Entity whenever the
+// weights are positive, such as in a negated field.
+type reachField interface {
+	reached(*Tile) bool
+}
+
+// fieldReached returns true if Follow on the Field may lead onto the Tile. A
+// Field which is not a reachField may lead onto any passable Tile.
+func fieldReached(f Field, t *Tile) bool {
+	if f, ok := f.(reachField); ok {
+		return f.reached(t)
+	}
+	return t.Pass
+}
+
 // sparseField implemenents Field using a sparse map of Tile weights.
 type sparseField struct {
 	weights map[*Tile]float64
 }
 
+// Weight implements WeightedField for sparseField.
+func (f *sparseField) Weight(t *Tile) float64 {
+	return f.weights[t]
+}
+
+// reached implements reachField for sparseField, so that combined fields only
+// lead onto Tiles which the sparseField reached.
+func (f *sparseField) reached(t *Tile) bool {
+	_, ok := f.weights[t]
+	return ok
+}
+
 // Follow returns an Offset from the given Tile which will lead to the
 // neighboring tile which has a lower weight. Goal weights are negative
-// so that the default value of 0 is neutral.
+// so that the default value of 0 is neutral. Since an Offset cannot describe a
+// change of Level, Follow returns the zero Offset on a Tile whose Stairs lead
+// towards the goal, and the caller should then Climb the Stairs with the lowest
+// weight.
 func (f *sparseField) Follow(t *Tile) Offset {
 	minWeight, minOffset := f.weights[t], Offset{}
 
@@ -34,49 +84,123 @@ func (f *sparseField) Follow(t *Tile) Offset {
 // towards the given goals. The edge weigts will be 0, with the goals
 // having a weight of -radius.
 func computeAttractWeights(radius int, goals []*Tile) map[*Tile]float64 {
+	return computeWeightedAttractWeights(float64(radius), unitCost, goals)
+}
+
+// computeWeightedAttractWeights computes the weights of a sparsefield which
+// pull towards the given goals, with the cost of each step given by a DistFn.
+// The goals have a weight of -radius, and no Tile will have a weight above 0.
+func computeWeightedAttractWeights(radius float64, cost DistFn, goals []*Tile) map[*Tile]float64 {
+	return Walking.attractWeights(radius, cost, goals)
+}
+
+// attractWeights computes the weights of a sparsefield which pull towards the
+// given goals, for an Entity using the Movement. The cost of each step is given
+// by the cost DistFn scaled by the Movement cost of the Tile being entered.
+// As with GraphSearch, the field spreads through Stairs as well as Adjacent
+// Tile, so it pulls towards the Stairs leading to a goal on another Level.
+func (m Movement) attractWeights(radius float64, cost DistFn, goals []*Tile) map[*Tile]float64 {
+	if m == nil {
+		m = Walking
+	}
+
 	// setup Djkstra's algorithm bookkeeping
 	weights := make(map[*Tile]float64)
-	queue := make([]*Tile, len(goals))
-	for i, goal := range goals {
-		weights[goal] = float64(-radius)
-		queue[i] = goal
+	queue := &weightqueue{}
+	for _, goal := range goals {
+		weights[goal] = -radius
+		queue.push(weightentry{tile: goal, weight: -radius})
 	}
 
 	// run Djkstra's algorithm to compute attractive weights from the goal
-	for len(queue) > 0 {
-		// pop the next Tile off the queue
-		curr := queue[0]
-		queue = queue[1:]
-
-		// if we've reached edge field, stop expanding the field
-		cost := weights[curr] + 1
-		if cost > 0 {
+	for queue.Len() > 0 {
+		// pop the next Tile off the queue, skipping it if we've since found
+		// a better weight for it
+		entry := queue.pop()
+		curr := entry.tile
+		if entry.weight > weights[curr] {
 			continue
 		}
 
-		// expand the frontier using neighbors of curr
-		for _, adj := range curr.Adjacent {
-			if _, seen := weights[adj]; !seen && adj.Pass {
-				weights[adj] = cost
-				queue = append(queue, adj)
+		// expand the frontier using neighbors of curr, stopping at the edge
+		expand := func(adj *Tile) {
+			if !m.Passable(adj) {
+				return
+			}
+			// if we've reached the edge of the field, stop expanding the field
+			weight := weights[curr] + cost(curr, adj)*m(adj)
+			if weight > 0 {
+				return
 			}
+			if prev, seen := weights[adj]; !seen || weight < prev {
+				weights[adj] = weight
+				queue.push(weightentry{tile: adj, weight: weight})
+			}
+		}
+		for _, adj := range curr.Adjacent {
+			expand(adj)
+		}
+		for _, adj := range curr.Stairs {
+			expand(adj)
 		}
 	}
 
 	return weights
 }
 
+// unitCost is a DistFn which gives every step a cost of 1.
+func unitCost(*Tile, *Tile) float64 {
+	return 1
+}
+
+// TileCost creates a DistFn from a per-Tile cost function. The cost of a step
+// is the cost of the Tile being stepped into.
+func TileCost(f func(*Tile) float64) DistFn {
+	return func(_, b *Tile) float64 {
+		return f(b)
+	}
+}
+
 // AttractiveField computes a Field which pulls towards the goal Tile.
 func AttractiveField(radius int, goals ...*Tile) Field {
 	return &sparseField{computeAttractWeights(radius, goals)}
 }
 
+// AttractiveField computes a Field which pulls towards the goal Tile for an
+// Entity using the Movement. The radius is measured in Movement cost.
+func (m Movement) AttractiveField(radius float64, goals ...*Tile) Field {
+	return &sparseField{m.attractWeights(radius, unitCost, goals)}
+}
+
+// WeightedAttractiveField computes a Field which pulls towards the goal Tile,
+// where the cost of each step is given by a DistFn. The radius is measured in
+// the same units as the cost, so terrain such as marsh or bramble can be made
+// more expensive to cross than a trail. The cost of a step should never be
+// negative.
+func WeightedAttractiveField(radius float64, cost DistFn, goals ...*Tile) Field {
+	return &sparseField{computeWeightedAttractWeights(radius, cost, goals)}
+}
+
 // ReplusiveField creates a Field which pulls towards the outermost edge of the
 // field with the given ungoals as the sources. This is *not* the same as
 // negating the weights of an attractive field, as the path towards the edge
-// of the field may require a step towards an ungoal.
+// of the field may require a step towards an ungoal. As with AttractiveField,
+// the field spreads through Stairs, so escaping may mean changing Levels.
 func ReplusiveField(radius int, ungoals ...*Tile) Field {
-	attractWeights := computeAttractWeights(radius, ungoals)
+	return Walking.ReplusiveField(float64(radius), ungoals...)
+}
+
+// ReplusiveField creates a Field which pulls towards the outermost edge of the
+// field with the given ungoals as the sources, for an Entity using the
+// Movement. The radius is measured in Movement cost, but the escape towards
+// the edge is measured in steps, so the weights match those of ReplusiveField
+// for Walking. An ungoal which the Movement cannot enter still gets a finite
+// weight, so long as its neighbors are within the field.
+func (m Movement) ReplusiveField(radius float64, ungoals ...*Tile) Field {
+	if m == nil {
+		m = Walking
+	}
+	attractWeights := m.attractWeights(radius, unitCost, ungoals)
 
 	// compute the weight of the edge of the attractive field
 	edgeWeight := math.Inf(-1)
@@ -84,7 +208,7 @@ func ReplusiveField(radius int, ungoals ...*Tile) Field {
 		edgeWeight = math.Max(edgeWeight, weight)
 	}
 
-	// create bookkeeping for djiksta's algorithm again
+	// create bookkeeping for breadth-first search from the edge
 	weights := make(map[*Tile]float64)
 	var queue []*Tile
 	for tile, weight := range attractWeights {
@@ -94,7 +218,7 @@ func ReplusiveField(radius int, ungoals ...*Tile) Field {
 		}
 	}
 
-	// perform djikstra algorith, with the restriction that we stay in the
+	// perform breadth-first search, with the restriction that we stay in the
 	// bounds of the original attractive field.
 	for len(queue) > 0 {
 		// pop the next Tile off the queue
@@ -102,7 +226,7 @@ func ReplusiveField(radius int, ungoals ...*Tile) Field {
 		queue = queue[1:]
 
 		cost := weights[curr] + 1
-		for _, adj := range curr.Adjacent {
+		expand := func(adj *Tile) {
 			_, seen := weights[adj]
 			_, keep := attractWeights[adj]
 			// only consider unseen nodes which are in the attractive field.
@@ -111,35 +235,190 @@ func ReplusiveField(radius int, ungoals ...*Tile) Field {
 				queue = append(queue, adj)
 			}
 		}
+		for _, adj := range curr.Adjacent {
+			expand(adj)
+		}
+		for _, adj := range curr.Stairs {
+			expand(adj)
+		}
 	}
 
 	return &sparseField{weights}
 }
 
-// funcField is a Filed which is composed of only a single function call.
-type funcField func(*Tile) Offset
+// SafetyField creates a Field which flees from the given threats, in the
+// style of the Brogue safety map. The weights of an attractive field are
+// inverted and scaled, and then rescanned so that each Tile weighs at most one
+// more than its neighbors. Unlike ReplusiveField, the resulting Field will
+// sometimes step towards a threat if doing so leads to much safer territory,
+// such as out of a dead end. As with ReplusiveField, the Field stays within the
+// bounds of the attractive field for the threats, which may include Tiles on
+// other Levels reached through Stairs.
+func SafetyField(radius int, threats ...*Tile) Field {
+	attractWeights := computeAttractWeights(radius, threats)
 
-// Follow simply calls the underlying field function.
-func (f funcField) Follow(t *Tile) Offset {
-	return f(t)
+	// invert the attractive weights, so that the threats are neutral and the
+	// edge of the field is the goal, and scale the result so that long detours
+	// are preferred over running into a corner.
+	weights := make(map[*Tile]float64, len(attractWeights))
+	queue := make([]*Tile, 0, len(attractWeights))
+	for tile, weight := range attractWeights {
+		weights[tile] = -safetyScale * (weight + float64(radius))
+		queue = append(queue, tile)
+	}
+
+	// rescan the weights, relaxing any weight which is more than one greater
+	// than one of its neighbors, staying in the bounds of the original field.
+	for len(queue) > 0 {
+		// pop the next Tile off the queue
+		curr := queue[0]
+		queue = queue[1:]
+
+		cost := weights[curr] + 1
+		expand := func(adj *Tile) {
+			if weight, keep := weights[adj]; keep && cost < weight {
+				weights[adj] = cost
+				queue = append(queue, adj)
+			}
+		}
+		for _, adj := range curr.Adjacent {
+			expand(adj)
+		}
+		for _, adj := range curr.Stairs {
+			expand(adj)
+		}
+	}
+
+	return &sparseField{weights}
 }
 
-// randField is the underlying function for RandomField.
-func randField(t *Tile) Offset {
-	candidates := make([]Offset, 0, len(t.Adjacent))
-	for offset, adj := range t.Adjacent {
-		if adj.Pass {
-			candidates = append(candidates, offset)
+// safetyScale is the amount the weights of a SafetyField are scaled by before
+// being rescanned. Larger values lead to longer detours to reach safety.
+const safetyScale = 1.2
+
+// weightField is a WeightedField whose weights are computed by a function,
+// typically by combining the weights of other Field. The field reaches any Tile
+// reached by one of the other Field.
+type weightField struct {
+	weight func(*Tile) float64
+	fields []Field
+}
+
+// Weight implements WeightedField for weightField.
+func (f *weightField) Weight(t *Tile) float64 {
+	return f.weight(t)
+}
+
+// reached implements reachField for weightField.
+func (f *weightField) reached(t *Tile) bool {
+	for _, field := range f.fields {
+		if fieldReached(field, t) {
+			return true
 		}
 	}
-	if len(candidates) == 0 {
-		return Offset{}
+	return false
+}
+
+// Follow returns an Offset from the given Tile which will lead to the
+// neighboring Tile which has the lowest weight. As with an AttractiveField,
+// only Tiles reached by the field are considered, so the combination of a
+// single Field follows the same steps as that Field. If no neighbor has a
+// lower weight than the given Tile, the zero Offset is returned.
+func (f *weightField) Follow(t *Tile) Offset {
+	minWeight, minOffset := f.weight(t), Offset{}
+
+	for offset, adj := range t.Adjacent {
+		if weight := f.weight(adj); weight < minWeight && f.reached(adj) {
+			minWeight = weight
+			minOffset = offset
+		}
 	}
-	return candidates[RandIntn(len(candidates))]
+
+	return minOffset
+}
+
+// SumField creates a Field whose weights are the sum of the weights of the
+// given Field. Combined with ScaleField, this gives a weighted sum, so that an
+// Entity can be pulled towards food while also being pushed away from fire.
+// Any Field which is not a WeightedField, such as RandomField, has a weight of
+// 0 everywhere, as with FieldWeight, and so contributes nothing to the sum.
+func SumField(fields ...Field) Field {
+	return &weightField{func(t *Tile) float64 {
+		sum := 0.0
+		for _, f := range fields {
+			sum += FieldWeight(f, t)
+		}
+		return sum
+	}, fields}
+}
+
+// MinField creates a Field whose weights are the minimum of the weights of the
+// given Field. As with SumField, a Field which is not a WeightedField has a
+// weight of 0 everywhere, so it caps the minimum at 0.
+func MinField(fields ...Field) Field {
+	return &weightField{func(t *Tile) float64 {
+		min := math.Inf(1)
+		for _, f := range fields {
+			min = math.Min(min, FieldWeight(f, t))
+		}
+		return min
+	}, fields}
+}
+
+// ScaleField creates a Field whose weights are the weights of the given Field
+// multiplied by a scalar. Scaling a Field which is not a WeightedField gives
+// a weight of 0 everywhere.
+func ScaleField(s float64, f Field) Field {
+	return &weightField{func(t *Tile) float64 {
+		return s * FieldWeight(f, t)
+	}, []Field{f}}
+}
+
+// NegField creates a Field whose weights are the negated weights of the given
+// Field. Note that negating an attractive field is *not* the same as creating a
+// ReplusiveField or SafetyField, as the negated field can get stuck in corners.
+func NegField(f Field) Field {
+	return ScaleField(-1, f)
+}
+
+// funcField is a Filed which is composed of only a single function call.
+type funcField func(*Tile) Offset
+
+// Follow simply calls the underlying field function.
+func (f funcField) Follow(t *Tile) Offset {
+	return f(t)
 }
 
 // RandomField is a Field which generates random Offsets. The resulting Offset
-// will always corespond to an adjacent Tile which is passable.
+// will always corespond to an adjacent Tile which is passable. RandomField
+// uses the global Dice.
 func RandomField() Field {
-	return funcField(randField)
+	return Walking.RandomField()
+}
+
+// RandomField is a Field which generates random Offsets using the global Dice,
+// as with RandomFieldWith.
+func (m Movement) RandomField() Field {
+	return m.RandomFieldWith(globalDice)
+}
+
+// RandomFieldWith is a Field which generates random Offsets using the given
+// Dice. The resulting Offset will always corespond to an adjacent Tile which
+// the Movement can enter.
+func (m Movement) RandomFieldWith(d Dice) Field {
+	if m == nil {
+		m = Walking
+	}
+	return funcField(func(t *Tile) Offset {
+		candidates := make([]Offset, 0, len(t.Adjacent))
+		for _, offset := range sortedSteps(t.Adjacent) {
+			if m.Passable(t.Adjacent[offset]) {
+				candidates = append(candidates, offset)
+			}
+		}
+		if len(candidates) == 0 {
+			return Offset{}
+		}
+		return candidates[d.Intn(len(candidates))]
+	})
 }
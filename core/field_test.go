@@ -184,3 +184,337 @@ func TestRepusliveField(t *testing.T) {
 		FieldCase{"ReplusiveField", c.g, c.r, ReplusiveFieldCase, ReplusiveField}.Run(t, i)
 	}
 }
+
+func TestSumFieldFollow(t *testing.T) {
+	// a sum of a single scaled attractive field should follow the same way
+	scaled := func(radius int, goals ...*Tile) Field {
+		return SumField(ScaleField(2, AttractiveField(radius, goals...)), RandomField())
+	}
+	cases := []StrGrid{
+		{
+			"#######",
+			"#@1234#",
+			"#11234#",
+			"#22234#",
+			"#33334#",
+			"#44444#",
+			"#######",
+		}, {
+			"########",
+			"#987666#",
+			"#####56#",
+			"#544456#",
+			"#543####",
+			"#54321@#",
+			"########",
+		},
+	}
+	for i, c := range cases {
+		FieldCase{"SumField", c, 10, AttractiveFieldCase, scaled}.Run(t, i)
+	}
+}
+
+func TestSumFieldImpassableGoal(t *testing.T) {
+	// a closed door is impassable, but both fields still lead to it
+	var origin, door *Tile
+	StrGrid{
+		"######",
+		"#@..+#",
+		"######",
+	}.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#', '+':
+			t.Pass = false
+		}
+		switch c {
+		case '@':
+			origin = t
+		case '+':
+			door = t
+		}
+	})
+	next := door.Adjacent[Offset{-1, 0}]
+	attract := AttractiveField(5, door)
+	if step := attract.Follow(next); step != (Offset{1, 0}) {
+		t.Errorf("AttractiveField stepped %v, expected (1, 0)", step)
+	}
+	if step := SumField(attract).Follow(next); step != attract.Follow(next) {
+		t.Errorf("SumField stepped %v, AttractiveField stepped %v", step, attract.Follow(next))
+	}
+
+	// a Field without weights contributes nothing
+	if step := SumField(RandomField()).Follow(origin); step != (Offset{}) {
+		t.Errorf("SumField of RandomField stepped %v", step)
+	}
+}
+
+func TestFieldArithmetic(t *testing.T) {
+	var a, b *Tile
+	StrGrid{
+		"#######",
+		"#a...b#",
+		"#######",
+	}.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#':
+			t.Pass = false
+		case 'a':
+			a = t
+		case 'b':
+			b = t
+		}
+	})
+	mid := a.Adjacent[Offset{1, 0}].Adjacent[Offset{1, 0}]
+
+	fa, fb := AttractiveField(10, a), AttractiveField(10, b)
+	cases := []struct {
+		name     string
+		field    Field
+		expected float64
+	}{
+		{"FieldWeight", fa, -8},
+		{"FieldWeight", RandomField(), 0},
+		{"SumField", SumField(fa, fb), -16},
+		{"MinField", MinField(fa, fb, ScaleField(3, fa)), -24},
+		{"ScaleField", ScaleField(.5, fb), -4},
+		{"NegField", NegField(fa), 8},
+	}
+	for _, c := range cases {
+		if actual := FieldWeight(c.field, mid); actual != c.expected {
+			t.Errorf("%s gave weight %f != %f", c.name, actual, c.expected)
+		}
+	}
+
+	// negating fa makes it repulsive, so it should step away from a
+	if step := NegField(fa).Follow(mid); step.X != 1 {
+		t.Errorf("NegField stepped %v", step)
+	}
+	// the sum is flat along the corridor, so there is nowhere to go
+	if step := SumField(fa, fb).Follow(mid); step != (Offset{}) {
+		t.Errorf("SumField stepped %v", step)
+	}
+}
+
+func TestSafetyField(t *testing.T) {
+	cases := []struct {
+		g StrGrid
+		r int
+	}{
+		{
+			StrGrid{
+				"#######",
+				"#Edcba#",
+				"#ddcba#",
+				"#cccba#",
+				"#bbbba#",
+				"#aaaaa#",
+				"#######",
+			}, 10,
+		},
+	}
+	for i, c := range cases {
+		FieldCase{"SafetyField", c.g, c.r, ReplusiveFieldCase, SafetyField}.Run(t, i)
+	}
+
+	// in a dead end, the safety field will pass the threat to escape
+	var threat, trapped *Tile
+	StrGrid{
+		"##############################",
+		"#.@T.........................#",
+		"##############################",
+	}.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#':
+			t.Pass = false
+		case '@':
+			trapped = t
+		case 'T':
+			threat = t
+		}
+	})
+	if step := SafetyField(30, threat).Follow(trapped); step.X != 1 {
+		t.Errorf("SafetyField did not escape dead end, stepped %v", step)
+	}
+}
+
+func TestAttractiveFieldStairs(t *testing.T) {
+	var origin, goal, upStair, downStair *Tile
+	StrGrid{
+		"######",
+		"#@..>#",
+		"######",
+	}.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#':
+			t.Pass = false
+		case '@':
+			origin = t
+		case '>':
+			downStair = t
+		}
+	})
+	StrGrid{
+		"######",
+		"#$..<#",
+		"######",
+	}.Convert(func(t *Tile, c byte) {
+		t.Level = 1
+		switch c {
+		case '#':
+			t.Pass = false
+		case '$':
+			goal = t
+		case '<':
+			upStair = t
+		}
+	})
+	LinkStairs(downStair, upStair)
+
+	field := AttractiveField(10, goal).(WeightedField)
+	if step := field.Follow(origin); step != (Offset{1, 0}) {
+		t.Errorf("AttractiveField stepped %v, expected towards the Stairs", step)
+	}
+	if step := field.Follow(downStair); step != (Offset{}) {
+		t.Errorf("AttractiveField stepped %v off the Stairs", step)
+	}
+	if field.Weight(upStair) >= field.Weight(downStair) {
+		t.Errorf("AttractiveField did not pull through the Stairs")
+	}
+
+	// fleeing a threat on the lower Level leads up the Stairs and away
+	if weight := FieldWeight(ReplusiveField(10, goal), origin); weight != 0 {
+		t.Errorf("ReplusiveField gave weight %v on the upper Level, expected 0", weight)
+	}
+	if weight := FieldWeight(SafetyField(10, goal), downStair); weight == 0 {
+		t.Errorf("SafetyField did not spread through the Stairs")
+	}
+}
+
+func TestWeightedAttractiveField(t *testing.T) {
+	var origin, goal *Tile
+	marsh := make(map[*Tile]struct{})
+	StrGrid{
+		"#########",
+		"#.......#",
+		"#.~~~~~.#",
+		"#@~~~~~$#",
+		"#########",
+	}.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#':
+			t.Pass = false
+		case '~':
+			marsh[t] = struct{}{}
+		case '@':
+			origin = t
+		case '$':
+			goal = t
+		}
+	})
+	cost := TileCost(func(t *Tile) float64 {
+		if _, ok := marsh[t]; ok {
+			return 3
+		}
+		return 1
+	})
+
+	// following the field should go around the marsh and reach the goal
+	field := WeightedAttractiveField(20, cost, goal)
+	curr := origin
+	for step := field.Follow(curr); step != (Offset{}); step = field.Follow(curr) {
+		curr = curr.Adjacent[step]
+		if _, ok := marsh[curr]; ok {
+			t.Errorf("WeightedAttractiveField stepped into marsh at %v", curr.Offset)
+		}
+	}
+	if curr != goal {
+		t.Errorf("WeightedAttractiveField did not reach goal")
+	}
+
+	// the radius is in cost units, so the far end of the marsh is out of range
+	field = WeightedAttractiveField(4, cost, goal)
+	if w := FieldWeight(field, origin); w != 0 {
+		t.Errorf("WeightedAttractiveField exceeded radius with weight %f", w)
+	}
+	if w := FieldWeight(field, goal.Adjacent[Offset{-1, 0}]); w != -1 {
+		t.Errorf("WeightedAttractiveField gave weight %f != -1", w)
+	}
+}
+
+func TestDynamicField(t *testing.T) {
+	dice := NewDice(newXorshift(1))
+	level := func(l int) []*Tile {
+		return NewTileGrid(20, 20, Offset{}, func(o Offset) *Tile {
+			t := NewTile(o)
+			t.Level = l
+			t.Pass = !dice.Chance(.25)
+			return t
+		})
+	}
+
+	// two Levels linked by a few Stairs, which the field must spread through
+	upper, lower := level(0), level(1)
+	for _, i := range []int{45, 250, 333} {
+		upper[i].Pass, lower[i].Pass = true, true
+		LinkStairs(upper[i], lower[i])
+	}
+	tiles := append(upper, lower...)
+	radius := 12
+	goals := []*Tile{tiles[0], tiles[210]}
+	field := NewDynamicField(radius, goals...)
+
+	check := func(op string) {
+		expected := AttractiveField(radius, goals...)
+		for _, tile := range tiles {
+			if FieldWeight(expected, tile) != field.Weight(tile) {
+				t.Errorf("DynamicField incorrect after %s at %v", op, tile.Offset)
+				return
+			}
+			if step := NegField(field).Follow(tile); step != (Offset{}) && !field.reached(tile.Adjacent[step]) {
+				t.Errorf("DynamicField led onto an unreached Tile after %s at %v", op, tile.Offset)
+				return
+			}
+		}
+	}
+
+	isGoal := func(t *Tile) bool {
+		for _, goal := range goals {
+			if t == goal {
+				return true
+			}
+		}
+		return false
+	}
+
+	check("NewDynamicField")
+	for i := 0; i < 200; i++ {
+		switch dice.Intn(4) {
+		case 0:
+			if goal := tiles[dice.Intn(len(tiles))]; !isGoal(goal) {
+				goals = append(goals, goal)
+				field.AddGoal(goal)
+				check("AddGoal")
+			}
+		case 1:
+			if len(goals) > 1 {
+				j := dice.Intn(len(goals))
+				field.RemoveGoal(goals[j])
+				goals = append(goals[:j], goals[j+1:]...)
+				check("RemoveGoal")
+			}
+		case 2:
+			j := dice.Intn(len(goals))
+			dst := goals[j].Adjacent[dice.Delta()]
+			if dst != nil && !isGoal(dst) {
+				field.MoveGoal(goals[j], dst)
+				goals[j] = dst
+				check("MoveGoal")
+			}
+		case 3:
+			tile := tiles[dice.Intn(len(tiles))]
+			tile.Pass = !tile.Pass
+			field.UpdateTile(tile)
+			check("UpdateTile")
+		}
+	}
+}
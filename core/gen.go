@@ -45,83 +45,98 @@ func isDiag(o Offset) bool {
 	return Abs(o.X) == 1 && Abs(o.Y) == 1
 }
 
-// connectDiagonals takes an orthogonally connected maze, and connects each Tile
-// diagonally through its neighbors.
-func connectDiagonals(origin *Tile) {
-	// FIXME only connect diagonals on the same z-level
-	// setup breadth-first graph traversal bookkeeping
-	frontier := []*Tile{origin}
-	visited := map[*Tile]struct{}{origin: {}}
+// cellGraph is a scratch graph of cells, used by generators which need to read
+// back what they have written, such as to add walls, before passing the result
+// on to a TileWriter. A cellGraph is itself a TileWriter.
+type cellGraph struct {
+	kinds map[Cell]int
+	links map[Cell]map[Offset]Cell
+	order []Cell
+}
+
+// newCellGraph creates an empty cellGraph.
+func newCellGraph() *cellGraph {
+	return &cellGraph{kinds: make(map[Cell]int), links: make(map[Cell]map[Offset]Cell)}
+}
 
-	for len(frontier) != 0 {
-		// pop the queue in breadth first fashion
-		curr := frontier[0]
-		frontier = frontier[1:]
+// SetCell implements TileWriter for cellGraph.
+func (g *cellGraph) SetCell(c Cell, kind int) {
+	if _, ok := g.kinds[c]; !ok {
+		g.order = append(g.order, c)
+		g.links[c] = make(map[Offset]Cell)
+	}
+	g.kinds[c] = kind
+}
 
+// Link implements TileWriter for cellGraph.
+func (g *cellGraph) Link(a, b Cell) {
+	step := b.Offset.Sub(a.Offset)
+	g.links[a][step] = b
+	g.links[b][step.Neg()] = a
+}
+
+// connectDiagonals takes an orthogonally connected graph, and connects each
+// cell diagonally through its neighbors. Only cells on the same Level are
+// connected.
+func (g *cellGraph) connectDiagonals() {
+	for _, curr := range g.order {
 		for _, step1 := range orthogonal {
 			// take two orthogonal steps to for a single diagonal step from curr
-			// if there is something there, connect curr and the resulting Tile
-			if adj, ok := curr.Adjacent[step1]; ok {
-				for step2, tile := range adj.Adjacent {
-					if diag := step1.Add(step2); isDiag(diag) {
-						curr.Adjacent[diag] = tile
-						tile.Adjacent[diag.Neg()] = curr
-					}
-				}
-
-				// enqueue any unvisted Tile
-				if _, seen := visited[adj]; !seen {
-					frontier = append(frontier, adj)
-					visited[adj] = struct{}{}
+			// if there is something there, connect curr and the resulting cell
+			adj, ok := g.links[curr][step1]
+			if !ok || adj.Level != curr.Level {
+				continue
+			}
+			for step2, cell := range g.links[adj] {
+				if diag := step1.Add(step2); isDiag(diag) && cell.Level == curr.Level {
+					g.Link(curr, cell)
 				}
 			}
 		}
 	}
 }
 
-// addWalls connects each passable tile to a wall where needed. Any added wall
-// Tile will be in the returned Tile slice. The provided MapGen will be used to
-// create any new wall Tile, so any wrapped MapGens should be created
-// accordingly. The wall Tiles will be fully connected, but those connection may
-// not be bi-directional. Consequently, the wall Tile adjancency maps should not
-// be used for field of view purposes. The returned slice of Tile will contain
-// any added wall Tile.
-func addWalls(origin *Tile, f MapGen) []*Tile {
-	var walls []*Tile
-
-	// setup breadth-first graph traversal bookkeeping
-	frontier := []*Tile{origin}
-	visited := map[*Tile]struct{}{origin: {}}
-
-	for len(frontier) != 0 {
-		// pop queue in breadth first fashion
-		curr := frontier[0]
-		frontier = frontier[1:]
-
-		// for each step, ensure we have an edge.
+// addWalls connects each cell which is not a wall to a wall cell in every
+// direction where it lacks a neighbor, writing the wall cells with the given
+// kind. Each added wall is placed on the same Level as the cell it borders,
+// and is shared by any other cell on that Level which borders it. A cell which
+// already exists is never turned into a wall, and is only linked if it is
+// itself a wall, so that a corridor running beside a room is not opened into
+// the room. Walls are only linked to the cells they border, so the wall links
+// should not be used for field of view purposes.
+func (g *cellGraph) addWalls(wall int) {
+	for _, curr := range g.order {
+		if g.kinds[curr] == wall {
+			continue
+		}
 		for _, step := range cardinal {
-			if adj, ok := curr.Adjacent[step]; ok {
-				// if the edge already exists, just add it if passable.
-				if _, seen := visited[adj]; !seen && adj.Pass {
-					frontier = append(frontier, adj)
-					visited[adj] = struct{}{}
-				}
-			} else {
-				// try and find a pre-made wall through my neighbors
-				off := curr.Offset.Add(step)
-				wall, ok := findWall(curr, off)
-				if !ok {
-					wall = f(off)
-					walls = append(walls, wall)
-				}
-				// connect to the wall - note that this connection means that
-				// nearby nodes will reuse this one when they are popped off
-				// the queue in breadth first fashion.
-				curr.Adjacent[step] = wall
-				wall.Adjacent[step.Neg()] = curr
+			if _, ok := g.links[curr][step]; ok {
+				continue
+			}
+			adj := Cell{curr.Offset.Add(step), curr.Level}
+			if kind, ok := g.kinds[adj]; !ok {
+				g.SetCell(adj, wall)
+			} else if kind != wall {
+				continue
 			}
+			g.Link(curr, adj)
 		}
 	}
+}
 
-	return walls
+// flush writes each cell and link of the cellGraph to a TileWriter, with the
+// cells in the order they were first written.
+func (g *cellGraph) flush(w TileWriter) {
+	index := make(map[Cell]int, len(g.order))
+	for i, c := range g.order {
+		w.SetCell(c, g.kinds[c])
+		index[c] = i
+	}
+	for i, c := range g.order {
+		for _, adj := range g.links[c] {
+			if index[adj] > i {
+				w.Link(c, adj)
+			}
+		}
+	}
 }
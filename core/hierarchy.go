@@ -0,0 +1,411 @@
+package core
+
+import (
+	"math"
+)
+
+// clusterKey identifies a single cluster in a Hierarchy.
+type clusterKey struct {
+	X, Y, Level int
+}
+
+// Hierarchy supports hierarchical pathfinding over large maps, such as a long
+// journey across an overworld. The map is divided into square clusters, and
+// the entrances between neighboring clusters, along with the costs between the
+// entrances of each cluster, are precomputed. A search then only needs to
+// explore the much smaller graph of entrances, and the resulting coarse path
+// is lazily refined into Tiles as it is walked.
+type Hierarchy struct {
+	size     int
+	clusters map[clusterKey][]*Tile
+	borders  map[[2]clusterKey]map[*Tile][]*Tile
+	intra    map[clusterKey]map[*Tile]map[*Tile]float64
+}
+
+// NewHierarchy creates a Hierarchy for the given Tiles, using clusters which
+// are size by size Tiles.
+func NewHierarchy(tiles []*Tile, size int) *Hierarchy {
+	h := &Hierarchy{
+		size,
+		make(map[clusterKey][]*Tile),
+		make(map[[2]clusterKey]map[*Tile][]*Tile),
+		make(map[clusterKey]map[*Tile]map[*Tile]float64),
+	}
+
+	for _, tile := range tiles {
+		k := h.clusterOf(tile)
+		h.clusters[k] = append(h.clusters[k], tile)
+	}
+	for k := range h.clusters {
+		for _, n := range h.neighbors(k) {
+			if _, built := h.borders[pairKey(k, n)]; !built {
+				h.buildBorder(k, n)
+			}
+		}
+	}
+	for k := range h.clusters {
+		h.buildIntra(k)
+	}
+
+	return h
+}
+
+// clusterOf computes the key of the cluster containing the given Tile.
+func (h *Hierarchy) clusterOf(t *Tile) clusterKey {
+	x, y := t.Offset.X, t.Offset.Y
+	return clusterKey{(x - Mod(x, h.size)) / h.size, (y - Mod(y, h.size)) / h.size, t.Level}
+}
+
+// neighbors gives the keys of each existing cluster next to the given cluster.
+func (h *Hierarchy) neighbors(k clusterKey) []clusterKey {
+	var keys []clusterKey
+	for _, step := range cardinal {
+		n := clusterKey{k.X + step.X, k.Y + step.Y, k.Level}
+		if _, ok := h.clusters[n]; ok {
+			keys = append(keys, n)
+		}
+	}
+	return keys
+}
+
+// pairKey gives an order independent key for a pair of clusters.
+func pairKey(a, b clusterKey) [2]clusterKey {
+	if a.X < b.X || a.X == b.X && a.Y < b.Y {
+		return [2]clusterKey{a, b}
+	}
+	return [2]clusterKey{b, a}
+}
+
+// buildBorder computes the entrances between two neighboring clusters. Each
+// connected run of crossings along the border gets a single entrance, placed
+// in the middle of the run. Crossings are only part of the same run if they
+// are adjacent on both sides of the border, so that every crossing in a run is
+// reachable from the entrance without leaving the run.
+func (h *Hierarchy) buildBorder(a, b clusterKey) {
+	links := make(map[*Tile][]*Tile)
+	h.borders[pairKey(a, b)] = links
+
+	// find each pair of passable Tiles which crosses from a into b
+	type crossing struct {
+		from, to *Tile
+	}
+	var crossings []crossing
+	for _, tile := range h.clusters[a] {
+		if !tile.Pass {
+			continue
+		}
+		for _, adj := range tile.Adjacent {
+			if adj.Pass && h.clusterOf(adj) == b {
+				crossings = append(crossings, crossing{tile, adj})
+			}
+		}
+	}
+
+	// near returns true if two Tiles are the same or adjacent
+	near := func(t, u *Tile) bool {
+		if t == u {
+			return true
+		}
+		for _, adj := range t.Adjacent {
+			if adj == u {
+				return true
+			}
+		}
+		return false
+	}
+
+	// group the crossings into connected runs, and link the middle of each
+	visited := make([]bool, len(crossings))
+	for start := range crossings {
+		if visited[start] {
+			continue
+		}
+		run := []int{start}
+		visited[start] = true
+		for i := 0; i < len(run); i++ {
+			curr := crossings[run[i]]
+			for j, other := range crossings {
+				if !visited[j] && near(curr.from, other.from) && near(curr.to, other.to) {
+					run = append(run, j)
+					visited[j] = true
+				}
+			}
+		}
+
+		froms := make([]*Tile, len(run))
+		for i, j := range run {
+			froms[i] = crossings[j].from
+		}
+		entrance := middleTile(froms)
+
+		// prefer an orthogonal crossing from the entrance
+		var exit *Tile
+		for _, j := range run {
+			if c := crossings[j]; c.from == entrance {
+				if exit == nil || !isDiag(c.to.Offset.Sub(c.from.Offset)) {
+					exit = c.to
+				}
+			}
+		}
+		links[entrance] = append(links[entrance], exit)
+		links[exit] = append(links[exit], entrance)
+	}
+}
+
+// middleTile returns the Tile closest to the centroid of the given Tiles.
+func middleTile(tiles []*Tile) *Tile {
+	var cx, cy float64
+	for _, t := range tiles {
+		cx += float64(t.Offset.X)
+		cy += float64(t.Offset.Y)
+	}
+	cx, cy = cx/float64(len(tiles)), cy/float64(len(tiles))
+
+	best, bestDist := tiles[0], math.Inf(1)
+	for _, t := range tiles {
+		if d := math.Hypot(float64(t.Offset.X)-cx, float64(t.Offset.Y)-cy); d < bestDist {
+			best, bestDist = t, d
+		}
+	}
+	return best
+}
+
+// entrances gives the set of entrance Tiles in the given cluster.
+func (h *Hierarchy) entrances(k clusterKey) map[*Tile]struct{} {
+	ents := make(map[*Tile]struct{})
+	for _, n := range h.neighbors(k) {
+		for tile := range h.borders[pairKey(k, n)] {
+			if h.clusterOf(tile) == k {
+				ents[tile] = struct{}{}
+			}
+		}
+	}
+	return ents
+}
+
+// clusterCost creates a DistFn which forbids leaving the given clusters.
+func (h *Hierarchy) clusterCost(keys ...clusterKey) DistFn {
+	return func(a, b *Tile) float64 {
+		k := h.clusterOf(b)
+		for _, allowed := range keys {
+			if k == allowed {
+				return euclidean(a, b)
+			}
+		}
+		return math.Inf(1)
+	}
+}
+
+// localCosts computes the cost from the given Tile to every Tile which can be
+// reached without leaving the given clusters.
+func (h *Hierarchy) localCosts(t *Tile, keys ...clusterKey) map[*Tile]float64 {
+	// no path within a cluster can be longer than visiting every Tile in the
+	// clusters, with each step costing at most a diagonal step.
+	radius := float64(2 * len(keys) * h.size * h.size)
+	weights := computeWeightedAttractWeights(radius, h.clusterCost(keys...), []*Tile{t})
+	for tile, weight := range weights {
+		weights[tile] = weight + radius
+	}
+	return weights
+}
+
+// buildIntra computes the costs between each pair of entrances in a cluster.
+func (h *Hierarchy) buildIntra(k clusterKey) {
+	intra := make(map[*Tile]map[*Tile]float64)
+	ents := h.entrances(k)
+	for src := range ents {
+		intra[src] = make(map[*Tile]float64)
+		costs := h.localCosts(src, k)
+		for dst := range ents {
+			if cost, ok := costs[dst]; ok && dst != src {
+				intra[src][dst] = cost
+			}
+		}
+	}
+	h.intra[k] = intra
+}
+
+// UpdateTile informs the Hierarchy that the passability of a Tile has changed,
+// so that the entrances and costs of the clusters around it can be repaired.
+// Only the cluster containing the Tile and its neighbors are recomputed.
+func (h *Hierarchy) UpdateTile(t *Tile) {
+	k := h.clusterOf(t)
+	neighbors := h.neighbors(k)
+	for _, n := range neighbors {
+		h.buildBorder(k, n)
+	}
+	h.buildIntra(k)
+	for _, n := range neighbors {
+		h.buildIntra(n)
+	}
+}
+
+// Route is a path found by a Hierarchy. The Route stores a coarse path through
+// cluster entrances, which is refined into individual Tiles as needed. A Route
+// is also a Field, so an Entity can simply Follow the Route each turn. If the
+// Entity strays from the Route, the next leg is refined from its position.
+type Route struct {
+	h         *Hierarchy
+	origin    *Tile
+	waypoints []*Tile
+	next      int
+	from      *Tile
+	leg       []*Tile
+}
+
+// Route finds a coarse path between two Tiles. If there is no path, then nil
+// is returned.
+func (h *Hierarchy) Route(origin, goal *Tile) *Route {
+	ko, kg := h.clusterOf(origin), h.clusterOf(goal)
+	originCosts, goalCosts := h.localCosts(origin, ko), h.localCosts(goal, kg)
+	originEnts := h.entrances(ko)
+
+	// short paths between neighboring clusters are often much better if they
+	// do not go through an entrance, so we also try a direct local path.
+	direct, hasDirect := 0.0, false
+	if dx, dy := kg.X-ko.X, kg.Y-ko.Y; kg.Level == ko.Level && Abs(dx) <= 1 && Abs(dy) <= 1 {
+		direct, hasDirect = h.localCosts(origin, ko, kg)[goal]
+	}
+
+	// neighbors gives each abstract node reachable from curr with the cost
+	neighbors := func(curr *Tile, visit func(*Tile, float64)) {
+		kc := h.clusterOf(curr)
+		if curr == origin {
+			for ent := range originEnts {
+				if cost, ok := originCosts[ent]; ok {
+					visit(ent, cost)
+				}
+			}
+			if hasDirect {
+				visit(goal, direct)
+			}
+		} else {
+			for dst, cost := range h.intra[kc][curr] {
+				visit(dst, cost)
+			}
+		}
+		for _, n := range h.neighbors(kc) {
+			for _, exit := range h.borders[pairKey(kc, n)][curr] {
+				visit(exit, euclidean(curr, exit))
+			}
+		}
+		if kc == kg {
+			if cost, ok := goalCosts[curr]; ok {
+				visit(goal, cost)
+			}
+		}
+	}
+
+	// perform A* over the abstract graph of entrances
+	gcost := map[*Tile]float64{origin: 0}
+	prev := make(map[*Tile]*Tile)
+	closed := make(map[*Tile]struct{})
+	queue := &weightqueue{{tile: origin, weight: euclidean(origin, goal)}}
+	for queue.Len() > 0 {
+		curr := queue.pop().tile
+		if _, seen := closed[curr]; seen {
+			continue
+		}
+		closed[curr] = struct{}{}
+
+		if curr == goal {
+			var waypoints []*Tile
+			for t := goal; t != origin; t = prev[t] {
+				waypoints = append(waypoints, t)
+			}
+			for i, j := 0, len(waypoints)-1; i < j; i, j = i+1, j-1 {
+				waypoints[i], waypoints[j] = waypoints[j], waypoints[i]
+			}
+			return &Route{h, origin, waypoints, 0, nil, nil}
+		}
+
+		neighbors(curr, func(adj *Tile, cost float64) {
+			if _, seen := closed[adj]; seen {
+				return
+			}
+			cost += gcost[curr]
+			if prevcost, ok := gcost[adj]; !ok || cost < prevcost {
+				gcost[adj] = cost
+				prev[adj] = curr
+				queue.push(weightentry{tile: adj, weight: cost + euclidean(adj, goal)})
+			}
+		})
+	}
+
+	return nil
+}
+
+// Search computes a full path between two Tiles using the Hierarchy. The result
+// is the same as refining an entire Route, and is suitable for use in place of
+// AStarPath, although the path is not guaranteed to be optimal.
+func (h *Hierarchy) Search(origin, goal *Tile) []*Tile {
+	if r := h.Route(origin, goal); r != nil {
+		return r.Tiles()
+	}
+	return nil
+}
+
+// refine computes the Tiles on a single leg of a Route, searching only the
+// clusters of the endpoints if possible.
+func (h *Hierarchy) refine(from, to *Tile) []*Tile {
+	cost := h.clusterCost(h.clusterOf(from), h.clusterOf(to))
+	if path := GraphSearch(from, to, cost, euclidean); path != nil {
+		return path
+	}
+	return AStarPath(from, to)
+}
+
+// Tiles refines the entire Route, giving every Tile from the origin (which is
+// excluded) to the goal.
+func (r *Route) Tiles() []*Tile {
+	var path []*Tile
+	from := r.origin
+	for _, waypoint := range r.waypoints {
+		path = append(path, r.h.refine(from, waypoint)...)
+		from = waypoint
+	}
+	return path
+}
+
+// Follow implements Field for Route. The resulting Offset leads from the given
+// Tile to the next Tile on the Route, refining the next leg of the Route if
+// needed. Once the goal is reached, or if there is no way to continue on the
+// Route, then the zero Offset is returned.
+func (r *Route) Follow(t *Tile) Offset {
+	// skip past any waypoints which have been reached
+	for i := r.next; i < len(r.waypoints); i++ {
+		if r.waypoints[i] == t {
+			r.next, r.leg = i+1, nil
+			break
+		}
+	}
+	if r.next >= len(r.waypoints) {
+		return Offset{}
+	}
+
+	// find where we are on the current leg, refining the leg if needed
+	index := -1
+	if r.leg != nil && t == r.from {
+		index = 0
+	}
+	for i, tile := range r.leg {
+		if tile == t {
+			index = i + 1
+		}
+	}
+	if index < 0 || index >= len(r.leg) {
+		r.from, r.leg = t, r.h.refine(t, r.waypoints[r.next])
+		index = 0
+		if len(r.leg) == 0 {
+			return Offset{}
+		}
+	}
+
+	next := r.leg[index]
+	for offset, adj := range t.Adjacent {
+		if adj == next {
+			return offset
+		}
+	}
+	return Offset{}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"testing"
+)
+
+func hierarchyTestGrid(dice Dice, cols, rows int, wallChance float64) []*Tile {
+	return NewTileGrid(cols, rows, Offset{}, func(o Offset) *Tile {
+		t := NewTile(o)
+		t.Pass = !dice.Chance(wallChance)
+		return t
+	})
+}
+
+func TestHierarchySearch(t *testing.T) {
+	dice := NewDice(newXorshift(1))
+	for i := 0; i < 20; i++ {
+		tiles := hierarchyTestGrid(dice, 50, 40, .25)
+		h := NewHierarchy(tiles, 8)
+		origin, goal := dice.PassTile(tiles), dice.PassTile(tiles)
+
+		expected, actual := AStarPath(origin, goal), h.Search(origin, goal)
+		if (expected == nil) != (actual == nil) {
+			t.Errorf("Hierarchy disagreed with AStarPath on reachability")
+			continue
+		}
+		if !PathValid(append([]*Tile{origin}, actual...)) || (len(actual) > 0 && actual[len(actual)-1] != goal) {
+			t.Errorf("Hierarchy gave invalid path")
+		}
+		// the Hierarchy is not optimal, but long paths should be close
+		if cost := PathCost(origin, expected); cost > 16 && PathCost(origin, actual) > 1.5*cost {
+			t.Errorf("Hierarchy path cost %f is much worse than %f", PathCost(origin, actual), PathCost(origin, expected))
+		}
+	}
+}
+
+func TestRouteFollow(t *testing.T) {
+	dice := NewDice(newXorshift(2))
+	tiles := hierarchyTestGrid(dice, 60, 60, .1)
+	h := NewHierarchy(tiles, 10)
+	origin, goal := tiles[0], tiles[len(tiles)-1]
+	origin.Pass, goal.Pass = true, true
+
+	route := h.Route(origin, goal)
+	if route == nil {
+		t.Fatalf("Hierarchy found no Route")
+	}
+	curr := origin
+	for i := 0; i < 1000 && curr != goal; i++ {
+		step := route.Follow(curr)
+		if step == (Offset{}) {
+			break
+		}
+		curr = curr.Adjacent[step]
+		if !curr.Pass {
+			t.Fatalf("Route stepped into impassable Tile")
+		}
+	}
+	if curr != goal {
+		t.Errorf("Route did not lead to goal")
+	}
+}
+
+func TestHierarchyUpdateTile(t *testing.T) {
+	var origin, goal *Tile
+	var door []*Tile
+	tiles := NewTileGrid(20, 10, Offset{}, NewTile)
+	for _, tile := range tiles {
+		switch {
+		case tile.Offset.X == 10:
+			tile.Pass = tile.Offset.Y == 5
+			if tile.Pass {
+				door = append(door, tile)
+			}
+		case tile.Offset == Offset{2, 2}:
+			origin = tile
+		case tile.Offset == Offset{17, 7}:
+			goal = tile
+		}
+	}
+
+	h := NewHierarchy(tiles, 4)
+	if h.Search(origin, goal) == nil {
+		t.Errorf("Hierarchy did not find path through door")
+	}
+
+	door[0].Pass = false
+	h.UpdateTile(door[0])
+	if h.Search(origin, goal) != nil {
+		t.Errorf("Hierarchy found path through closed door")
+	}
+
+	door[0].Pass = true
+	h.UpdateTile(door[0])
+	if h.Search(origin, goal) == nil {
+		t.Errorf("Hierarchy did not find path through reopened door")
+	}
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"math"
+)
+
+// The kinds of water which a Hydrology can place in a cell.
+const (
+	WaterNone = iota
+	WaterRiver
+	WaterLake
+	WaterSea
+)
+
+// Hydrology is a water layer for an overworld generated from a Heightmap. Water
+// drains off the edges of the map and into the sea, meaning any cell below the
+// SeaLevel. Depressions with no outlet are filled to their spill point, and any
+// cell flooded deeper than LakeDepth becomes a lake. Each cell then passes its
+// rain downhill, and cells whose accumulated flow reaches RiverThreshold become
+// rivers. Rivers are carved into the Heightmap by lowering them by Carve, down
+// to the SeaLevel at most. If a MoistureMap is given, each cell contributes
+// rain in proportion to its moisture, otherwise every cell contributes 1.
+type Hydrology struct {
+	height *Heightmap
+	filled [][]float64
+	flow   [][]Offset
+	accum  [][]float64
+	water  [][]int
+
+	SeaLevel       float64
+	RiverThreshold float64
+	LakeDepth      float64
+	Carve          float64
+	Moisture       *MoistureMap
+}
+
+// NewHydrology creates a Hydrology for the given Heightmap with default
+// parameters. Generate must be called after the Heightmap is generated, and
+// before any water is read.
+func NewHydrology(h *Heightmap) *Hydrology {
+	return &Hydrology{
+		height:         h,
+		SeaLevel:       .4,
+		RiverThreshold: float64(h.cols+h.rows) / 2,
+		LakeDepth:      .005,
+		Carve:          .01,
+	}
+}
+
+// newHydrologyGrid creates a grid of values with the dimensions of a Heightmap.
+func newHydrologyGrid(h *Heightmap) [][]float64 {
+	grid := make([][]float64, h.cols)
+	for x := range grid {
+		grid[x] = make([]float64, h.rows)
+	}
+	return grid
+}
+
+// Generate fills depressions, computes the flow of water across the Heightmap,
+// places rivers and lakes, and carves the rivers into the Heightmap.
+func (w *Hydrology) Generate() {
+	h := w.height
+	cols, rows := h.cols, h.rows
+	w.filled = newHydrologyGrid(h)
+	w.accum = newHydrologyGrid(h)
+	w.flow = make([][]Offset, cols)
+	w.water = make([][]int, cols)
+	for x := 0; x < cols; x++ {
+		w.flow[x] = make([]Offset, rows)
+		w.water[x] = make([]int, rows)
+	}
+
+	// priority-flood from the drains (the sea and the edges of the map),
+	// always expanding the lowest cell, so that each cell is discovered from
+	// the cell it drains into. Cells lower than the cell which discovered
+	// them are in a depression, and are raised to drain.
+	seen := make([][]bool, cols)
+	for x := range seen {
+		seen[x] = make([]bool, rows)
+	}
+	queue := &weightqueue{}
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			edge := y == 0 || y == rows-1 || !h.WrapX && (x == 0 || x == cols-1)
+			if edge || h.buf[x][y] < w.SeaLevel {
+				seen[x][y] = true
+				w.filled[x][y] = h.buf[x][y]
+				queue.push(weightentry{index: x*rows + y, weight: h.buf[x][y]})
+			}
+		}
+	}
+
+	var order []Offset
+	for queue.Len() > 0 {
+		i := queue.pop().index
+		curr := Offset{i / rows, i % rows}
+		order = append(order, curr)
+
+		for _, step := range cardinal {
+			x, y := curr.X+step.X, curr.Y+step.Y
+			if h.WrapX {
+				x = Mod(x, cols)
+			}
+			if !InBounds(x, y, cols, rows) || seen[x][y] {
+				continue
+			}
+			seen[x][y] = true
+			w.flow[x][y] = step.Neg()
+			w.filled[x][y] = math.Max(h.buf[x][y], math.Nextafter(w.filled[curr.X][curr.Y], math.Inf(1)))
+			queue.push(weightentry{index: x*rows + y, weight: w.filled[x][y]})
+		}
+	}
+
+	// pass the rain downstream, starting from the highest cells, which were
+	// the last to be discovered.
+	for _, o := range order {
+		w.accum[o.X][o.Y] = 1
+		if w.Moisture != nil {
+			w.accum[o.X][o.Y] = w.Moisture.buf[o.X][o.Y]
+		}
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		o := order[i]
+		if step := w.flow[o.X][o.Y]; step != (Offset{}) {
+			x, y := o.X+step.X, o.Y+step.Y
+			if h.WrapX {
+				x = Mod(x, cols)
+			}
+			w.accum[x][y] += w.accum[o.X][o.Y]
+		}
+	}
+
+	// classify the water in each cell, carving the rivers as we go
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			switch {
+			case h.buf[x][y] < w.SeaLevel:
+				w.water[x][y] = WaterSea
+			case w.filled[x][y]-h.buf[x][y] > w.LakeDepth:
+				w.water[x][y] = WaterLake
+			case w.accum[x][y] >= w.RiverThreshold:
+				w.water[x][y] = WaterRiver
+				h.buf[x][y] = math.Max(w.SeaLevel, h.buf[x][y]-w.Carve)
+			}
+		}
+	}
+}
+
+// Water gets the kind of water in a specific cell, such as WaterRiver.
+func (w *Hydrology) Water(x, y int) int {
+	return w.water[x][y]
+}
+
+// Flow gets the step which water takes out of a specific cell. Cells which
+// drain off the map or into the sea have a zero Offset.
+func (w *Hydrology) Flow(x, y int) Offset {
+	return w.flow[x][y]
+}
+
+// Accumulation gets the amount of rain which flows through a specific cell,
+// including the rain which fell on the cell itself.
+func (w *Hydrology) Accumulation(x, y int) float64 {
+	return w.accum[x][y]
+}
+
+// WaterAt gets the kind of water where a Tile stands, assuming that the Tile
+// was created by applying the Heightmap, so that its Offset gives its cell.
+func (w *Hydrology) WaterAt(t *Tile) int {
+	x, y := w.height.cell(t.Offset)
+	return w.Water(x, y)
+}
+
+// NewMapGen wraps a MapGenFloat so that rivers and lakes are generated using
+// their own MapGenFloat, with every other cell generated by land. If either
+// the river or lake MapGenFloat is nil, land is used instead.
+func (w *Hydrology) NewMapGen(land, river, lake MapGenFloat) MapGenFloat {
+	return func(o Offset, height float64) *Tile {
+		x, y := w.height.cell(o)
+		switch w.water[x][y] {
+		case WaterRiver:
+			if river != nil {
+				return river(o, height)
+			}
+		case WaterLake:
+			if lake != nil {
+				return lake(o, height)
+			}
+		}
+		return land(o, height)
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// valleyHeightmap creates a Heightmap with a valley running down the middle
+// column, which slopes down towards the bottom edge of the map.
+func valleyHeightmap(cols, rows int) *Heightmap {
+	h := NewHeightmap(cols, rows)
+	h.WrapX = false
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			h.Write(x, y, .5+.05*math.Abs(float64(x-cols/2))+.01*float64(rows-y))
+		}
+	}
+	return h
+}
+
+func TestHydrologyRiver(t *testing.T) {
+	h := valleyHeightmap(11, 20)
+	w := NewHydrology(h)
+	w.RiverThreshold = 30
+	before := h.Read(5, 15)
+	w.Generate()
+
+	// the valley floor should carry a river, while the slopes stay dry
+	if w.Water(5, 15) != WaterRiver {
+		t.Errorf("valley floor was not a river")
+	}
+	if w.Water(5, 1) != WaterNone || w.Water(2, 15) != WaterNone {
+		t.Errorf("river formed upstream or on the valley slopes")
+	}
+	if w.Flow(4, 10) != (Offset{1, 0}) && w.Flow(4, 10) != (Offset{1, 1}) {
+		t.Errorf("slope drained in direction %v", w.Flow(4, 10))
+	}
+	if h.Read(5, 15) >= before {
+		t.Errorf("river was not carved into the Heightmap")
+	}
+
+	// every drop of rain should drain off the map exactly once
+	total := 0.0
+	for x := 0; x < 11; x++ {
+		for y := 0; y < 20; y++ {
+			if w.Flow(x, y) == (Offset{}) {
+				total += w.Accumulation(x, y)
+			}
+		}
+	}
+	if math.Abs(total-11*20) > 1e-9 {
+		t.Errorf("drained %v rain, expected %v", total, 11*20)
+	}
+}
+
+func TestHydrologyLake(t *testing.T) {
+	// a bowl whose center is lower than its rim
+	h := NewHeightmap(9, 9)
+	h.WrapX = false
+	for x := 0; x < 9; x++ {
+		for y := 0; y < 9; y++ {
+			d := math.Max(math.Abs(float64(x-4)), math.Abs(float64(y-4)))
+			h.Write(x, y, .9-.1*math.Abs(d-3))
+		}
+	}
+	h.Write(0, 4, .1)
+
+	w := NewHydrology(h)
+	w.Generate()
+	if w.Water(4, 4) != WaterLake || w.Water(3, 3) != WaterLake {
+		t.Errorf("basin did not form a lake")
+	}
+	if w.Water(0, 4) != WaterSea {
+		t.Errorf("low cell did not form a sea")
+	}
+	if w.Water(1, 1) == WaterLake {
+		t.Errorf("rim of basin formed a lake")
+	}
+}
+
+func TestHydrologyMapGen(t *testing.T) {
+	h := valleyHeightmap(11, 20)
+	w := NewHydrology(h)
+	w.RiverThreshold = 30
+	w.Generate()
+
+	gen := func(ch rune) MapGenFloat {
+		return func(o Offset, height float64) *Tile {
+			t := NewTile(o)
+			t.Face.Ch = ch
+			return t
+		}
+	}
+	tiles := h.Apply(w.NewMapGen(gen('.'), gen('~'), nil))
+	for _, tile := range tiles {
+		river := tile.Face.Ch == '~'
+		if river != (w.WaterAt(tile) == WaterRiver) {
+			t.Errorf("NewMapGen did not place river at %v", tile.Offset)
+		}
+	}
+}
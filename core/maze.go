@@ -1,35 +1,118 @@
 package core
 
+import (
+	"sort"
+)
+
 // MapGenBool generates Tiles from bool values to form various mazes.
 type MapGenBool func(o Offset, pass bool) *Tile
 
-// PerfectMaze creates a set of Tile which form a perfect maze (meaning the
+// PerfectMaze creates a set of Tile as with PerfectMazeWith, making every
+// random choice with the global Dice.
+func (f MapGenBool) PerfectMaze(n int, runProb, weaveProb float64) []*Tile {
+	return f.PerfectMazeWith(globalDice, n, runProb, weaveProb)
+}
+
+// PerfectMazeWith creates a set of Tile which form a perfect maze (meaning the
 // maze has no loops). The value of n specifies the size of the underlying graph
 // describing the maze, which is related to but not equal to the number of Tile
 // in the result maze. The runProb specifies how often the algorithm will try
-// to continue extending a corridor, as opposed to starting a new branch.
-func (f MapGenBool) PerfectMaze(n int, runProb, weaveProb float64) []*Tile {
-	return applyMaze(abstractPerfect(n, runProb, weaveProb), f)
+// to continue extending a corridor, as opposed to starting a new branch. Every
+// random choice is made with the given Dice, so the same seed always gives the
+// same maze.
+func (f MapGenBool) PerfectMazeWith(d Dice, n int, runProb, weaveProb float64) []*Tile {
+	return applyMaze(abstractPerfect(d, n, runProb, weaveProb), f)
 }
 
-// BraidMaze creates a set of Tile which form a braid maze (meaning the maze
+// BraidMaze creates a set of Tile as with BraidMazeWith, making every random
+// choice with the global Dice.
+func (f MapGenBool) BraidMaze(n int, runProb, weaveProb float64) []*Tile {
+	return f.BraidMazeWith(globalDice, n, runProb, weaveProb)
+}
+
+// BraidMazeWith creates a set of Tile which form a braid maze (meaning the maze
 // has no dead ends). The value of n specifies the size of the underlying graph
 // describing the maze, which is related to but not equal to the number of Tile
 // in the result maze. The runProb specifies how often the algorithm will try
-// to continue extending a corridor, as opposed to starting a new branch.
-func (f MapGenBool) BraidMaze(n int, runProb, weaveProb float64) []*Tile {
-	return applyMaze(abstractBraid(n, runProb, weaveProb, 1), f)
+// to continue extending a corridor, as opposed to starting a new branch. Every
+// random choice is made with the given Dice.
+func (f MapGenBool) BraidMazeWith(d Dice, n int, runProb, weaveProb float64) []*Tile {
+	return applyMaze(abstractBraid(d, n, runProb, weaveProb, 1), f)
 }
 
-// HalfBraidMaze creates a set of Tile which form a half-braid maze (meaning the
-// maze will have some dead ends and some loops). The value of n specifies the
-// size of the underlying graph describing the maze, which is related to but not
-// equal to the number of Tile in the result maze. The runProb specifies how
-// often the algorithm will try to continue extending a corridor, as opposed to
-// starting a new branch. The loopProb is the probability of removing a
-// deadend by creating a loop.
+// HalfBraidMaze creates a set of Tile as with HalfBraidMazeWith, making every
+// random choice with the global Dice.
 func (f MapGenBool) HalfBraidMaze(n int, runProb, weaveProb, loopProb float64) []*Tile {
-	return applyMaze(abstractBraid(n, runProb, weaveProb, loopProb), f)
+	return f.HalfBraidMazeWith(globalDice, n, runProb, weaveProb, loopProb)
+}
+
+// HalfBraidMazeWith creates a set of Tile which form a half-braid maze (meaning
+// the maze will have some dead ends and some loops). The value of n specifies
+// the size of the underlying graph describing the maze, which is related to but
+// not equal to the number of Tile in the result maze. The runProb specifies how
+// often the algorithm will try to continue extending a corridor, as opposed to
+// starting a new branch. The loopProb is the probability of removing a deadend
+// by creating a loop. Every random choice is made with the given Dice.
+func (f MapGenBool) HalfBraidMazeWith(d Dice, n int, runProb, weaveProb, loopProb float64) []*Tile {
+	return applyMaze(abstractBraid(d, n, runProb, weaveProb, loopProb), f)
+}
+
+// kinds adapts a MapGenBool for use with a TileGraphWriter. The maze and cave
+// generators write passable cells as TileTypeCorridor and walls as
+// TileTypeWall, so any kind other than TileTypeWall is passable.
+func (f MapGenBool) kinds() MapGenInt {
+	return func(o Offset, kind int) *Tile {
+		return f(o, kind != TileTypeWall)
+	}
+}
+
+// boolKind gives the kind written for a passable or wall cell by the maze and
+// cave generators.
+func boolKind(pass bool) int {
+	if pass {
+		return TileTypeCorridor
+	}
+	return TileTypeWall
+}
+
+// WritePerfectMaze writes a maze to a TileWriter as with WritePerfectMazeWith,
+// making every random choice with the global Dice.
+func WritePerfectMaze(w TileWriter, n int, runProb, weaveProb float64) {
+	WritePerfectMazeWith(w, globalDice, n, runProb, weaveProb)
+}
+
+// WritePerfectMazeWith writes a perfect maze (meaning the maze has no loops) to
+// a TileWriter, as with PerfectMaze. Passable cells are TileTypeCorridor, and
+// walls are TileTypeWall. Every random choice is made with the given Dice.
+func WritePerfectMazeWith(w TileWriter, d Dice, n int, runProb, weaveProb float64) {
+	writeMaze(abstractPerfect(d, n, runProb, weaveProb), w)
+}
+
+// WriteBraidMaze writes a maze to a TileWriter as with WriteBraidMazeWith,
+// making every random choice with the global Dice.
+func WriteBraidMaze(w TileWriter, n int, runProb, weaveProb float64) {
+	WriteBraidMazeWith(w, globalDice, n, runProb, weaveProb)
+}
+
+// WriteBraidMazeWith writes a braid maze (meaning the maze has no dead ends) to
+// a TileWriter, as with BraidMaze. Passable cells are TileTypeCorridor, and
+// walls are TileTypeWall. Every random choice is made with the given Dice.
+func WriteBraidMazeWith(w TileWriter, d Dice, n int, runProb, weaveProb float64) {
+	writeMaze(abstractBraid(d, n, runProb, weaveProb, 1), w)
+}
+
+// WriteHalfBraidMaze writes a maze to a TileWriter as with
+// WriteHalfBraidMazeWith, making every random choice with the global Dice.
+func WriteHalfBraidMaze(w TileWriter, n int, runProb, weaveProb, loopProb float64) {
+	WriteHalfBraidMazeWith(w, globalDice, n, runProb, weaveProb, loopProb)
+}
+
+// WriteHalfBraidMazeWith writes a half-braid maze (meaning the maze will have
+// some dead ends and some loops) to a TileWriter, as with HalfBraidMaze.
+// Passable cells are TileTypeCorridor, and walls are TileTypeWall. Every random
+// choice is made with the given Dice.
+func WriteHalfBraidMazeWith(w TileWriter, d Dice, n int, runProb, weaveProb, loopProb float64) {
+	writeMaze(abstractBraid(d, n, runProb, weaveProb, loopProb), w)
 }
 
 // defaultMapGenBool is used in the generic versions of each MapGenBool method.
@@ -50,9 +133,9 @@ var defaultMapGenBool = func(o Offset, pass bool) *Tile {
 // in the result maze. The runProb specifies how often the algorithm will try
 // to continue extending a corridor, as opposed to starting a new branch.
 // PerfectMazes uses a default MapGenBool which generates white '.' for passable
-// Tile and white '#' for wall Tile.
+// Tile and white '#' for wall Tile, and the global Dice.
 func PerfectMaze(n int, runProb, weaveProb float64) []*Tile {
-	return applyMaze(abstractPerfect(n, runProb, weaveProb), defaultMapGenBool)
+	return MapGenBool(defaultMapGenBool).PerfectMaze(n, runProb, weaveProb)
 }
 
 // BraidMaze creates a set of Tile which form a braid maze (meaning the maze
@@ -61,9 +144,9 @@ func PerfectMaze(n int, runProb, weaveProb float64) []*Tile {
 // in the result maze. The runProb specifies how often the algorithm will try
 // to continue extending a corridor, as opposed to starting a new branch.
 // BraidMaze uses a default MapGenBool which generates white '.' for passable
-// Tile and white '#' for wall Tile.
+// Tile and white '#' for wall Tile, and the global Dice.
 func BraidMaze(n int, runProb, weaveProb float64) []*Tile {
-	return applyMaze(abstractBraid(n, runProb, weaveProb, 1), defaultMapGenBool)
+	return MapGenBool(defaultMapGenBool).BraidMaze(n, runProb, weaveProb)
 }
 
 // HalfBraidMaze creates a set of Tile which form a half-braid maze (meaning the
@@ -73,9 +156,10 @@ func BraidMaze(n int, runProb, weaveProb float64) []*Tile {
 // often the algorithm will try to continue extending a corridor, as opposed to
 // starting a new branch. The loopProb is the probability of removing a
 // deadend by creating a loop. HalfBraidMaze uses a default MapGenBool which
-// generates white '.' for passable Tile and white '#' for wall Tile.
+// generates white '.' for passable Tile and white '#' for wall Tile, and the
+// global Dice.
 func HalfBraidMaze(n int, runProb, weaveProb, loopProb float64) []*Tile {
-	return applyMaze(abstractBraid(n, runProb, weaveProb, loopProb), defaultMapGenBool)
+	return MapGenBool(defaultMapGenBool).HalfBraidMaze(n, runProb, weaveProb, loopProb)
 }
 
 // mazenode is a single node (in the graph sense) in an abstractmaze
@@ -89,17 +173,48 @@ type abstractmaze struct {
 	Nodes map[Offset][]*mazenode
 }
 
-// GetArbitraryNode returns a mazenode from the maze node list. Since the node
-// is arbitrarily chosen, the caller cannot depend on the node being the same
-// across multiple calls, nor can it be depended upon to be chosen randomly.
+// GetArbitraryNode returns a mazenode from the maze node list. The node is
+// the first given by SortedNodes, so it is the same for mazes with the same
+// nodes, but it cannot be depended upon to be chosen randomly.
 func (m *abstractmaze) GetArbitraryNode() *mazenode {
-	for _, nodelist := range m.Nodes {
-		for _, node := range nodelist {
-			return node
+	if nodes := m.SortedNodes(); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+// SortedNodes returns every mazenode ordered by position, with the nodes which
+// share a position in the order they were added. Ranging over the node map
+// directly would give a different order on each run, so generators use this
+// to make the same random choices given the same Dice.
+func (m *abstractmaze) SortedNodes() []*mazenode {
+	positions := make([]Offset, 0, len(m.Nodes))
+	for pos := range m.Nodes {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].X != positions[j].X {
+			return positions[i].X < positions[j].X
 		}
+		return positions[i].Y < positions[j].Y
+	})
+
+	var nodes []*mazenode
+	for _, pos := range positions {
+		nodes = append(nodes, m.Nodes[pos]...)
 	}
+	return nodes
+}
 
-	return nil
+// SortedEdges returns the steps of the edges of the mazenode in a fixed order.
+func (n *mazenode) SortedEdges() []Offset {
+	steps := make([]Offset, 0, len(n.Edges))
+	for _, step := range orthogonal {
+		if _, ok := n.Edges[step]; ok {
+			steps = append(steps, step)
+		}
+	}
+	return steps
 }
 
 // Data needed by maze generation to iterate through directional Offsets.
@@ -124,7 +239,7 @@ var (
 
 // abstractPerfect generates an abstractmaze with the given number of nodes.
 // This maze will be perfect, meaning that it has no loops.
-func abstractPerfect(n int, runProb, weaveProb float64) *abstractmaze {
+func abstractPerfect(d Dice, n int, runProb, weaveProb float64) *abstractmaze {
 	// set up bookkeeping for growing tree algorithm
 	origin := &mazenode{Offset{}, make(map[Offset]*mazenode)}
 	maze := &abstractmaze{map[Offset][]*mazenode{origin.Pos: {origin}}}
@@ -137,10 +252,10 @@ func abstractPerfect(n int, runProb, weaveProb float64) *abstractmaze {
 	for nodesAdded < n {
 		// select a node at random, meaning we emulate Prim's algorithm
 		var index int
-		if RandChance(runProb) {
+		if d.Chance(runProb) {
 			index = len(frontier) - 1
 		} else {
-			index = RandIntn(len(frontier))
+			index = d.Intn(len(frontier))
 		}
 		curr := frontier[index]
 
@@ -149,14 +264,14 @@ func abstractPerfect(n int, runProb, weaveProb float64) *abstractmaze {
 		for _, step := range orthogonal {
 			_, used := curr.Edges[step]
 			_, exists := maze.Nodes[curr.Pos.Add(step)]
-			if !used && (!exists || RandChance(weaveProb)) {
+			if !used && (!exists || d.Chance(weaveProb)) {
 				candidates = append(candidates, step)
 			}
 		}
 
 		if len(candidates) > 0 {
 			// create the adjacent node in the step direction
-			step := candidates[RandIntn(len(candidates))]
+			step := candidates[d.Intn(len(candidates))]
 			adjpos := curr.Pos.Add(step)
 			adjnode := &mazenode{adjpos, make(map[Offset]*mazenode)}
 
@@ -177,24 +292,24 @@ func abstractPerfect(n int, runProb, weaveProb float64) *abstractmaze {
 	return maze
 }
 
-// abstractPerfect generates an abstractmaze with the given number of nodes.
+// abstractBraid generates an abstractmaze with the given number of nodes.
 // This maze will be a braid, meaning that it has no deadends.
-func abstractBraid(n int, runProb, weaveProb, loopProb float64) *abstractmaze {
-	maze := abstractPerfect(n, runProb, weaveProb)
-	removeDeadends(maze, loopProb)
+func abstractBraid(d Dice, n int, runProb, weaveProb, loopProb float64) *abstractmaze {
+	maze := abstractPerfect(d, n, runProb, weaveProb)
+	removeDeadends(d, maze, loopProb)
 	return maze
 }
 
 // removeDeadends removes a given percent of deadends from an abstractmaze.
 // Deadends are removed by adding an edge to an unconnected but adjacent node.
 // Deadends which have no unconnected adjacent node are simply removed.
-func removeDeadends(m *abstractmaze, loopProb float64) {
+func removeDeadends(d Dice, m *abstractmaze, loopProb float64) {
 	origin := m.GetArbitraryNode()
 
 	// find all the dead ends - nodes which have only one edge
 	deadends := []*mazenode{}
 	frontier := []*mazenode{origin}
-	visited := map[*mazenode]struct{}{origin: {}}
+	visited := map[*mazenode]bool{origin: true}
 	for len(frontier) != 0 {
 		curr := frontier[len(frontier)-1]
 		frontier = frontier[:len(frontier)-1]
@@ -203,10 +318,10 @@ func removeDeadends(m *abstractmaze, loopProb float64) {
 			deadends = append(deadends, curr)
 		}
 
-		for _, adj := range curr.Edges {
-			if _, seen := visited[adj]; !seen {
+		for _, step := range curr.SortedEdges() {
+			if adj := curr.Edges[step]; !visited[adj] {
 				frontier = append(frontier, adj)
-				visited[adj] = struct{}{}
+				visited[adj] = true
 			}
 		}
 	}
@@ -223,7 +338,7 @@ func removeDeadends(m *abstractmaze, loopProb float64) {
 			continue
 		}
 
-		if !RandChance(loopProb) {
+		if !d.Chance(loopProb) {
 			continue
 		}
 
@@ -252,7 +367,8 @@ func removeDeadends(m *abstractmaze, loopProb float64) {
 			// find the node adjacent to the deadend, and delete its edge
 			// to the deadend. it will either be the next dead end to prune
 			// or will be left alone if it has 2+ edges remaining.
-			for step, adj := range deadend.Edges {
+			for _, step := range deadend.SortedEdges() {
+				adj := deadend.Edges[step]
 				delete(adj.Edges, step.Neg())
 				if len(adj.Edges) == 1 {
 					deadends = append(deadends, adj)
@@ -261,7 +377,7 @@ func removeDeadends(m *abstractmaze, loopProb float64) {
 			// FIXME add a replacement node for each deleted node
 		} else {
 			// pick a neighboring node, and connect an edge to the neighbor
-			neighbor := candidates[RandIntn(len(candidates))]
+			neighbor := candidates[d.Intn(len(candidates))]
 			step := neighbor.Pos.Sub(deadend.Pos)
 			deadend.Edges[step] = neighbor
 			neighbor.Edges[step.Neg()] = deadend
@@ -279,97 +395,59 @@ func remove(l []*mazenode, n *mazenode) []*mazenode {
 	return l
 }
 
-// applyMaze converts each node and edge an abstract maze to a single Tile.
-// The origin Tile of the maze is returned.
+// applyMaze creates the Tiles for an abstract maze using the MapGenBool.
 func applyMaze(m *abstractmaze, f MapGenBool) []*Tile {
-	origin := createPassTiles(m, f)
-	connectDiagonals(origin)
-	addWalls(origin, func(o Offset) *Tile {
-		return f(o, false)
-	})
+	w := NewTileGraphWriter(f.kinds())
+	writeMaze(m, w)
+	return w.Tiles()
+}
 
-	var maze []*Tile
-	frontier := []*Tile{origin}
-	visited := map[*Tile]struct{}{origin: {}}
-	for len(frontier) != 0 {
-		curr := frontier[len(frontier)-1]
-		frontier = frontier[:len(frontier)-1]
-		for _, adj := range curr.Adjacent {
-			if _, seen := visited[adj]; !seen {
-				frontier = append(frontier, adj)
-				visited[adj] = struct{}{}
-				maze = append(maze, adj)
-			}
+// nodeLevel gives the Level of the Tile for a mazenode. Weaving places
+// multiple nodes at the same position, so each is given its own Level.
+func (m *abstractmaze) nodeLevel(n *mazenode) int {
+	for i, node := range m.Nodes[n.Pos] {
+		if node == n {
+			return i
 		}
 	}
-	return maze
+	return 0
 }
 
-// createPassTiles returns a set of passable Tiles (given by the origin Tile)
-// corresponding to the nodes and edges of an abstractmaze.
-func createPassTiles(m *abstractmaze, f MapGenBool) *Tile {
-	origin := m.GetArbitraryNode()
-
-	// graph traversal bookkeeping
-	frontier := []*mazenode{origin}
-	visited := map[*mazenode]*Tile{origin: f(Offset{}, true)}
-
-	for len(frontier) != 0 {
-		// pop the next node and grab its corresponding Tile
-		node := frontier[len(frontier)-1]
-		frontier = frontier[:len(frontier)-1]
-		nodeTile := visited[node]
-
-		// for each edge, create a Tile for the edge, and (if needed) a Tile for
-		// the adjacent node
-		for step, adj := range node.Edges {
-			if _, edgeExists := nodeTile.Adjacent[step]; !edgeExists {
-				negStep := step.Neg()
-
-				// create a Tile corresponding to the edge between node and adj
-				edgeOff := nodeTile.Offset.Add(step)
-				edgeTile := f(edgeOff, true)
-				nodeTile.Adjacent[step] = edgeTile
-				edgeTile.Adjacent[negStep] = nodeTile
-
-				// connect the edge Tile to a Tile corresponding to adj
-				adjTile, seen := visited[adj]
-				// The edge not existing is nessesary but sufficient for the
-				// adj to have not been seen and created since we could have
-				// seen the adj, but not popped it from the frontier yet. Thus,
-				// this check is needed so we don't create the adj node twice.
-				if !seen {
-					adjTile = f(edgeOff.Add(step), true)
-					// Since we found a new node, we should enqueue it now
-					visited[adj] = adjTile
-					frontier = append(frontier, adj)
-				}
-				edgeTile.Adjacent[step] = adjTile
-				adjTile.Adjacent[negStep] = edgeTile
-			}
-		}
-	}
+// nodeCell gives the Cell for a mazenode. Nodes are spaced two cells apart,
+// leaving room for a cell for each edge between them.
+func (m *abstractmaze) nodeCell(n *mazenode) Cell {
+	return Cell{Offset{2 * n.Pos.X, 2 * n.Pos.Y}, m.nodeLevel(n)}
+}
 
-	return visited[m.GetArbitraryNode()]
+// writeMaze writes a passable cell for each node and edge of an abstractmaze,
+// and then surrounds them with walls.
+func writeMaze(m *abstractmaze, w TileWriter) {
+	g := mazeGraph(m)
+	g.addWalls(TileTypeWall)
+	g.flush(w)
 }
 
-// findWall queries the neighbors of origin for a Tile at the given dest.
-func findWall(origin *Tile, dest Offset) (tile *Tile, ok bool) {
-	for _, adj := range origin.Adjacent {
-		adjStep := dest.Sub(adj.Offset)
-		if tile, exists := adj.Adjacent[adjStep]; exists {
-			return tile, true
+// mazeGraph builds a cellGraph with a passable cell for each node and edge of
+// an abstractmaze, connected diagonally but not yet surrounded by walls. The
+// edge cells between nodes on different Levels take the higher Level, so that
+// a weave crossing forms a ramp.
+func mazeGraph(m *abstractmaze) *cellGraph {
+	g := newCellGraph()
+	for _, node := range m.SortedNodes() {
+		nodeCell := m.nodeCell(node)
+		g.SetCell(nodeCell, TileTypeCorridor)
+		for _, step := range node.SortedEdges() {
+			adjCell := m.nodeCell(node.Edges[step])
+			edgeCell := Cell{nodeCell.Offset.Add(step), Max(nodeCell.Level, adjCell.Level)}
+			g.SetCell(edgeCell, TileTypeCorridor)
+			g.SetCell(adjCell, TileTypeCorridor)
+			g.Link(nodeCell, edgeCell)
+			g.Link(edgeCell, adjCell)
 		}
 	}
-	return nil, false
+
+	g.connectDiagonals()
+	return g
 }
 
 // TODO Add dungeon
-// TODO Add caveify
-// TODO Use writer interfaces instead of directly writing Tile
-
-// TODO Add function to connect overworld with maze
-// allow caller to specify entrance/exit criteria
-// translate maze coordinates to match overworld coordinates
-// fully connect maze entrance with overworld
-// fully connect overworld connection neighbors with maze
@@ -23,19 +23,46 @@ func (e ComponentSlice) Handle(v Event) {
 	}
 }
 
-// Tile is an Entity representing a single square in a map.
+// Tile is an Entity representing a single square in a map. The Level allows
+// several maps (such as caves beneath an overworld) to share Offsets. Adjacent
+// Tile are normally on the same Level, although ramps such as the crossings in
+// a weave maze may join two Levels. Otherwise, Tiles on different Levels are
+// linked through Stairs, which are keyed by the change in Level. Higher Levels
+// are deeper, so the Stairs down from a Tile have the key 1 and the Stairs up
+// have the key -1.
 type Tile struct {
 	Face     Glyph
 	Pass     bool
 	Lite     bool
 	Offset   Offset
+	Level    int
 	Adjacent map[Offset]*Tile
+	Stairs   map[int]*Tile
 	Occupant Entity
 }
 
 // NewTile creates a new Tile with no neighbors or occupant.
 func NewTile(o Offset) *Tile {
-	return &Tile{Glyph{'.', ColorWhite}, true, true, o, make(map[Offset]*Tile), nil}
+	return &Tile{Glyph{'.', ColorWhite}, true, true, o, 0, make(map[Offset]*Tile), nil, nil}
+}
+
+// LinkStairs creates a bi-directional stair link between two Tiles on
+// different Levels. If the Tiles are on the same Level, ErrSameLevel is
+// returned and no link is made.
+func LinkStairs(a, b *Tile) error {
+	delta := b.Level - a.Level
+	if delta == 0 {
+		return ErrSameLevel
+	}
+	if a.Stairs == nil {
+		a.Stairs = make(map[int]*Tile)
+	}
+	if b.Stairs == nil {
+		b.Stairs = make(map[int]*Tile)
+	}
+	a.Stairs[delta] = b
+	b.Stairs[-delta] = a
+	return nil
 }
 
 // Handle implements Entity for Tile
@@ -48,9 +75,23 @@ func (e *Tile) Handle(v Event) {
 		}
 	case *MoveEntity:
 		adj := e.Adjacent[v.Delta]
+		if v.Climb != 0 {
+			if adj = e.Stairs[v.Climb]; adj == nil {
+				e.Occupant.Handle(&Collide{e})
+				return
+			}
+		}
 		if bumped := adj.Occupant; bumped != nil {
-			e.Occupant.Handle(&Bump{bumped})
-		} else if adj.Pass {
+			swap := &Swap{Swapper: e.Occupant}
+			bumped.Handle(swap)
+			if swap.Accept && v.Movement.Passable(adj) && swap.Movement.Passable(e) {
+				e.Occupant, adj.Occupant = bumped, e.Occupant
+				e.Occupant.Handle(&UpdatePos{e})
+				adj.Occupant.Handle(&UpdatePos{adj})
+			} else {
+				e.Occupant.Handle(&Bump{bumped})
+			}
+		} else if v.Movement.Passable(adj) {
 			e.Occupant, adj.Occupant = nil, e.Occupant
 			adj.Occupant.Handle(&UpdatePos{adj})
 		} else {
@@ -65,8 +106,14 @@ type RenderRequest struct {
 }
 
 // MoveEntity is an Event attempting to move an occupant to a new position.
+// If Climb is non-zero, the occupant instead attempts to take the Stairs
+// which change the Level by Climb, and Delta is ignored. If there are no such
+// Stairs, the occupant is sent a Collide with its own Tile. The Movement of
+// the occupant decides which Tiles it can enter, with nil meaning Walking.
 type MoveEntity struct {
-	Delta Offset
+	Delta    Offset
+	Climb    int
+	Movement Movement
 }
 
 // UpdatePos is an Event informing an Entity of its new position.
@@ -79,6 +126,18 @@ type Bump struct {
 	Bumped Entity
 }
 
+// Swap is an Event asking an Entity whether it will trade places with another
+// Entity which is trying to move onto its Tile. If the Entity sets Accept, such
+// as when the Swapper is an ally, the two Entities trade places, provided that
+// each can enter the Tile of the other. The Entity sets Movement to its own
+// Movement, with nil meaning Walking. Otherwise the Swapper is sent a Bump as
+// usual.
+type Swap struct {
+	Swapper  Entity
+	Accept   bool
+	Movement Movement
+}
+
 // Collide is an Event in which an Entity collides with an obstacle.
 type Collide struct {
 	Obstacle Entity
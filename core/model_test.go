@@ -0,0 +1,162 @@
+package core
+
+import (
+	"testing"
+)
+
+// posRecorder is an Entity which records its position from UpdatePos.
+type posRecorder struct {
+	pos *Tile
+}
+
+// Handle implements Entity for posRecorder.
+func (e *posRecorder) Handle(v Event) {
+	if v, ok := v.(*UpdatePos); ok {
+		e.pos = v.Pos
+	}
+}
+
+func TestLinkStairs(t *testing.T) {
+	upper, lower := NewTile(Offset{}), NewTile(Offset{})
+	if err := LinkStairs(upper, lower); err != ErrSameLevel {
+		t.Errorf("LinkStairs linked Tiles on the same Level")
+	}
+
+	lower.Level = 1
+	if err := LinkStairs(upper, lower); err != nil {
+		t.Errorf("LinkStairs gave error %v", err)
+	}
+	if upper.Stairs[1] != lower || lower.Stairs[-1] != upper {
+		t.Errorf("LinkStairs did not link both directions")
+	}
+}
+
+// collider is a posRecorder which also records the obstacle of a Collide.
+type collider struct {
+	posRecorder
+	obstacle Entity
+}
+
+// Handle implements Entity for collider.
+func (e *collider) Handle(v Event) {
+	if v, ok := v.(*Collide); ok {
+		e.obstacle = v.Obstacle
+	}
+	e.posRecorder.Handle(v)
+}
+
+func TestMoveEntityClimb(t *testing.T) {
+	upper, lower := NewTile(Offset{}), NewTile(Offset{})
+	lower.Level = 1
+	LinkStairs(upper, lower)
+
+	e := &collider{posRecorder{upper}, nil}
+	upper.Occupant = e
+	upper.Handle(&MoveEntity{Climb: 1})
+	if e.pos != lower || lower.Occupant != e || upper.Occupant != nil {
+		t.Errorf("MoveEntity did not climb down the Stairs")
+	}
+
+	lower.Handle(&MoveEntity{Climb: 1})
+	if e.pos != lower || lower.Occupant != e {
+		t.Errorf("MoveEntity climbed missing Stairs")
+	}
+	if e.obstacle != lower {
+		t.Errorf("MoveEntity did not Collide at missing Stairs")
+	}
+
+	lower.Handle(&MoveEntity{Climb: -1})
+	if e.pos != upper || upper.Occupant != e || lower.Occupant != nil {
+		t.Errorf("MoveEntity did not climb up the Stairs")
+	}
+}
+
+func TestConnectDiagonalsLevel(t *testing.T) {
+	// a plus shape with the right arm raised to another Level
+	g := newCellGraph()
+	center := Cell{Offset{0, 0}, 0}
+	g.SetCell(center, TileTypeCorridor)
+	arms := make(map[Offset]Cell)
+	for _, step := range orthogonal {
+		arm := Cell{step, 0}
+		if step == (Offset{1, 0}) {
+			arm.Level = 1
+		}
+		g.SetCell(arm, TileTypeCorridor)
+		g.Link(center, arm)
+		arms[step] = arm
+	}
+
+	g.connectDiagonals()
+	if _, ok := g.links[arms[Offset{0, 1}]][Offset{-1, -1}]; !ok {
+		t.Errorf("connectDiagonals did not connect cells on the same Level")
+	}
+	if _, ok := g.links[arms[Offset{0, 1}]][Offset{1, -1}]; ok {
+		t.Errorf("connectDiagonals connected cells on different Levels")
+	}
+}
+
+// swapper is a posRecorder which always accepts a Swap, moving with the given
+// Movement.
+type swapper struct {
+	posRecorder
+	movement Movement
+}
+
+// Handle implements Entity for swapper.
+func (e *swapper) Handle(v Event) {
+	if v, ok := v.(*Swap); ok {
+		v.Accept = true
+		v.Movement = e.movement
+	}
+	e.posRecorder.Handle(v)
+}
+
+func TestMoveEntitySwap(t *testing.T) {
+	left, right := NewTile(Offset{0, 0}), NewTile(Offset{1, 0})
+	left.Adjacent[Offset{1, 0}] = right
+	right.Adjacent[Offset{-1, 0}] = left
+
+	mover, ally := &posRecorder{left}, &swapper{posRecorder{right}, nil}
+	left.Occupant, right.Occupant = mover, ally
+	left.Handle(&MoveEntity{Delta: Offset{1, 0}})
+	if mover.pos != right || ally.pos != left || left.Occupant != ally || right.Occupant != mover {
+		t.Errorf("MoveEntity did not swap with accepting Entity")
+	}
+
+	left.Handle(&MoveEntity{Delta: Offset{1, 0}})
+	if mover.pos != right || ally.pos != left || left.Occupant != ally || right.Occupant != mover {
+		t.Errorf("MoveEntity swapped with refusing Entity")
+	}
+}
+
+func TestMoveEntitySwapMovement(t *testing.T) {
+	land, water := NewTile(Offset{0, 0}), NewTile(Offset{1, 0})
+	water.Face.Ch, water.Pass = '~', false
+	land.Adjacent[Offset{1, 0}] = water
+	water.Adjacent[Offset{-1, 0}] = land
+	swimming := Profile(map[rune]float64{'~': 1}, nil)
+
+	// a walker cannot swap into the water, even with a willing fish
+	walker, fish := &posRecorder{land}, &swapper{posRecorder{water}, swimming}
+	land.Occupant, water.Occupant = walker, fish
+	land.Handle(&MoveEntity{Delta: Offset{1, 0}})
+	if land.Occupant != walker || water.Occupant != fish {
+		t.Errorf("MoveEntity swapped a walker into water")
+	}
+
+	// nor can a swimmer swap a fish out of the water onto land
+	swimmer := &posRecorder{land}
+	land.Occupant = swimmer
+	land.Handle(&MoveEntity{Delta: Offset{1, 0}, Movement: swimming})
+	if land.Occupant != swimmer || water.Occupant != fish {
+		t.Errorf("MoveEntity swapped a fish onto land")
+	}
+
+	// but a walking ally on land can swap
+	fish.movement = nil
+	land.Handle(&MoveEntity{Delta: Offset{1, 0}, Movement: swimming})
+	if land.Occupant != fish || water.Occupant != swimmer {
+		t.Errorf("MoveEntity did not swap when both could move")
+	}
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"math"
+)
+
+// Movement describes how an Entity moves through the Tile graph, giving the
+// cost of stepping into each Tile. A cost of math.Inf(1) means that the Tile is
+// impassable. Different species can use different Movement on the same map, so
+// that a fish swims through water which a mammoth cannot enter.
+type Movement func(*Tile) float64
+
+// Walking is the default Movement, for which each passable Tile costs 1 and
+// every other Tile is impassable.
+var Walking Movement = walking
+
+// walking is the underlying function for Walking.
+func walking(t *Tile) float64 {
+	if t.Pass {
+		return 1
+	}
+	return math.Inf(1)
+}
+
+// Profile creates a Movement from the cost of entering each kind of Tile, where
+// the kind is given by the character of the Tile Face. Kinds which are missing
+// from the costs use the fallback Movement, or are impassable if the fallback
+// is nil. For example, a swimming human might use a Profile giving water a cost
+// of 3 with Walking as the fallback, while a fish uses a Profile giving water a
+// cost of 1 with no fallback. Costs should be at least 1, since AStarPath and
+// the other searches using the euclidean heuristic assume that no step is
+// cheaper than its length.
+func Profile(costs map[rune]float64, fallback Movement) Movement {
+	return func(t *Tile) float64 {
+		if cost, ok := costs[t.Face.Ch]; ok {
+			return cost
+		}
+		if fallback != nil {
+			return fallback(t)
+		}
+		return math.Inf(1)
+	}
+}
+
+// Passable returns true if the Movement can enter the Tile. A nil Movement is
+// treated as Walking.
+func (m Movement) Passable(t *Tile) bool {
+	if m == nil {
+		return t.Pass
+	}
+	return !math.IsInf(m(t), 1)
+}
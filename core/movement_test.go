@@ -0,0 +1,197 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// MovementCase converts a StrGrid in which '~' is impassable water, '#' is an
+// impassable wall, '@' is the origin and '$' is the goal.
+func MovementCase(g StrGrid) (origin, goal *Tile) {
+	g.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#', '~':
+			t.Pass = false
+		case '@':
+			origin = t
+		case '$':
+			goal = t
+		}
+		t.Face.Ch = rune(c)
+	})
+	return origin, goal
+}
+
+var (
+	fish    = Profile(map[rune]float64{'~': 1}, nil)
+	swimmer = Profile(map[rune]float64{'~': 3}, Walking)
+)
+
+func TestProfile(t *testing.T) {
+	land, water, wall := NewTile(Offset{}), NewTile(Offset{}), NewTile(Offset{})
+	water.Face.Ch, water.Pass = '~', false
+	wall.Face.Ch, wall.Pass = '#', false
+
+	cases := []struct {
+		name     string
+		movement Movement
+		tile     *Tile
+		expected float64
+	}{
+		{"Walking", Walking, land, 1},
+		{"Walking", Walking, water, math.Inf(1)},
+		{"fish", fish, water, 1},
+		{"fish", fish, land, math.Inf(1)},
+		{"swimmer", swimmer, water, 3},
+		{"swimmer", swimmer, land, 1},
+		{"swimmer", swimmer, wall, math.Inf(1)},
+	}
+	for _, c := range cases {
+		if actual := c.movement(c.tile); actual != c.expected {
+			t.Errorf("%s cost %c = %v, expected %v", c.name, c.tile.Face.Ch, actual, c.expected)
+		}
+	}
+}
+
+func TestMovementAStarPath(t *testing.T) {
+	grid := StrGrid{
+		"###########",
+		"#.........#",
+		"#.#######.#",
+		"#@~~~~~~~$#",
+		"###########",
+	}
+
+	cases := []struct {
+		name     string
+		movement Movement
+		expected float64
+	}{
+		{"Walking", Walking, 2*math.Sqrt2 + 8},
+		{"fish", fish, math.Inf(1)},
+		{"swimmer", swimmer, 2*math.Sqrt2 + 8},
+	}
+	for _, c := range cases {
+		origin, goal := MovementCase(grid)
+		path := c.movement.AStarPath(origin, goal)
+		if c.expected == math.Inf(1) {
+			if path != nil {
+				t.Errorf("%s found path onto land", c.name)
+			}
+			continue
+		}
+		if !PathValid(path) || path[len(path)-1] != goal {
+			t.Errorf("%s gave invalid path", c.name)
+		} else if cost := PathCost(origin, path); math.Abs(cost-c.expected) > 1e-6 {
+			t.Errorf("%s path cost %v, expected %v", c.name, cost, c.expected)
+		}
+	}
+
+	// a fish can cross the water, once the goal is in the water
+	origin, goal := MovementCase(grid)
+	origin.Face.Ch, goal.Face.Ch = '~', '~'
+	if path := fish.AStarPath(origin, goal); len(path) != 8 {
+		t.Errorf("fish gave path of length %d, expected 8", len(path))
+	}
+}
+
+func TestMovementAttractiveField(t *testing.T) {
+	origin, goal := MovementCase(StrGrid{
+		"#########",
+		"#@~~~~~$#",
+		"#########",
+	})
+	origin.Face.Ch, goal.Face.Ch = '~', '~'
+
+	if step := AttractiveField(10, goal).Follow(origin); step != (Offset{}) {
+		t.Errorf("AttractiveField crossed water")
+	}
+	if step := fish.AttractiveField(10, goal).Follow(origin); step != (Offset{1, 0}) {
+		t.Errorf("fish AttractiveField gave %v, expected (1, 0)", step)
+	}
+	repulse := fish.ReplusiveField(10, origin)
+	if FieldWeight(repulse, goal) != 0 || FieldWeight(repulse, origin) != 6 {
+		t.Errorf("fish ReplusiveField gave wrong weights")
+	}
+	if step := fish.RandomField().Follow(origin); step != (Offset{1, 0}) {
+		t.Errorf("fish RandomField gave %v, expected (1, 0)", step)
+	}
+}
+
+func TestMoveEntityMovement(t *testing.T) {
+	land, water := NewTile(Offset{0, 0}), NewTile(Offset{1, 0})
+	water.Face.Ch, water.Pass = '~', false
+	land.Adjacent[Offset{1, 0}] = water
+	water.Adjacent[Offset{-1, 0}] = land
+
+	e := &posRecorder{land}
+	land.Occupant = e
+	land.Handle(&MoveEntity{Delta: Offset{1, 0}})
+	if e.pos != land || land.Occupant != e {
+		t.Errorf("MoveEntity walked into water")
+	}
+
+	land.Handle(&MoveEntity{Delta: Offset{1, 0}, Movement: swimmer})
+	if e.pos != water || water.Occupant != e || land.Occupant != nil {
+		t.Errorf("MoveEntity did not swim into water")
+	}
+}
+
+func TestMovementNil(t *testing.T) {
+	origin, goal := MovementCase(StrGrid{
+		"#######",
+		"#@.#.$#",
+		"#.....#",
+		"#######",
+	})
+
+	var m Movement
+	if path := m.AStarPath(origin, goal); !PathValid(path) || len(path) != 4 {
+		t.Errorf("nil Movement AStarPath gave %d steps, expected 4", len(path))
+	}
+	if _, ok := m.LimitedSearch(origin, goal, euclidean, euclidean, SearchLimit{}); !ok {
+		t.Errorf("nil Movement LimitedSearch did not reach goal")
+	}
+	if FieldWeight(m.AttractiveField(10, goal), origin) != FieldWeight(AttractiveField(10, goal), origin) {
+		t.Errorf("nil Movement AttractiveField differs from Walking")
+	}
+	if FieldWeight(m.ReplusiveField(10, goal), goal) != FieldWeight(ReplusiveField(10, goal), goal) {
+		t.Errorf("nil Movement ReplusiveField differs from Walking")
+	}
+	for i := 0; i < 10; i++ {
+		if step := m.RandomField().Follow(origin); !origin.Adjacent[step].Pass {
+			t.Errorf("nil Movement RandomField stepped into a wall")
+		}
+	}
+}
+
+func TestMovementReplusiveFieldSteps(t *testing.T) {
+	// the ungoal is a wall, and the swimmer pays 3 for the water, which only
+	// counts as a single step when escaping
+	_, goal := MovementCase(StrGrid{
+		"#######",
+		"#$.~..#",
+		"#######",
+	})
+	goal.Pass = false
+
+	cases := []struct {
+		name     string
+		movement Movement
+		radius   float64
+		expected []float64
+	}{
+		{"Walking", Walking, 5, []float64{1, 0}},
+		{"swimmer", swimmer, 5, []float64{3, 2, 1, 0}},
+	}
+	for _, c := range cases {
+		field := c.movement.ReplusiveField(c.radius, goal)
+		tile := goal
+		for i, expected := range c.expected {
+			if weight := FieldWeight(field, tile); weight != expected {
+				t.Errorf("%s ReplusiveField weight %d = %v, expected %v", c.name, i, weight, expected)
+			}
+			tile = tile.Adjacent[Offset{1, 0}]
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"math"
+)
+
+// GradientNoise is two dimensional Perlin style gradient noise. The noise is
+// smooth, has values in roughly [-1, 1], and is 0 at each integer lattice
+// point. Each GradientNoise has its own permutation of the lattice gradients,
+// so different GradientNoise give different noise.
+type GradientNoise struct {
+	perm [256]int
+}
+
+// NewGradientNoise creates a new GradientNoise as with NewGradientNoiseWith,
+// using the global Dice.
+func NewGradientNoise() *GradientNoise {
+	return NewGradientNoiseWith(globalDice)
+}
+
+// NewGradientNoiseWith creates a new GradientNoise, using the given Dice to
+// shuffle the lattice gradients.
+func NewGradientNoiseWith(d Dice) *GradientNoise {
+	n := &GradientNoise{}
+	copy(n.perm[:], d.Perm(256))
+	return n
+}
+
+// gradients are the unit vectors used as lattice gradients.
+var gradients = [8][2]float64{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{math.Sqrt2 / 2, math.Sqrt2 / 2}, {-math.Sqrt2 / 2, math.Sqrt2 / 2},
+	{math.Sqrt2 / 2, -math.Sqrt2 / 2}, {-math.Sqrt2 / 2, -math.Sqrt2 / 2},
+}
+
+// fade is the quintic smoothing curve used to interpolate between lattice
+// points, which has zero first and second derivatives at 0 and 1.
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+// lerp linearly interpolates between a and b.
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// grad computes the contribution of a lattice point to the noise.
+func (n *GradientNoise) grad(ix, iy int, dx, dy float64) float64 {
+	g := gradients[n.perm[(n.perm[ix&255]+iy)&255]&7]
+	return g[0]*dx + g[1]*dy
+}
+
+// Noise samples the noise at the given point. If period is positive, the noise
+// repeats in x with the given period, so that it can be wrapped seamlessly
+// around a map. The period should be an integer number of lattice cells.
+func (n *GradientNoise) Noise(x, y float64, period int) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix0, iy0 := int(x0), int(y0)
+	ix1, iy1 := ix0+1, iy0+1
+	if period > 0 {
+		ix0, ix1 = Mod(ix0, period), Mod(ix1, period)
+	}
+
+	u, v := fade(fx), fade(fy)
+	bottom := lerp(u, n.grad(ix0, iy0, fx, fy), n.grad(ix1, iy0, fx-1, fy))
+	top := lerp(u, n.grad(ix0, iy1, fx, fy-1), n.grad(ix1, iy1, fx-1, fy-1))
+	return lerp(v, bottom, top)
+}
+
+// Fractal specifies fractal noise made by summing octaves of GradientNoise.
+// The first octave has Frequency lattice cells across the width of the map.
+// Each later octave multiplies the frequency by Lacunarity and the amplitude by
+// Persistence, adding finer and fainter detail. If Ridged is set, each octave
+// is folded into sharp ridges, which resemble mountain ranges. If Warp is
+// non-zero, the point sampled is first displaced by another fractal noise,
+// scaled by Warp lattice cells, which twists the terrain into more natural
+// shapes.
+type Fractal struct {
+	Octaves     int
+	Frequency   float64
+	Lacunarity  float64
+	Persistence float64
+	Ridged      bool
+	Warp        float64
+}
+
+// DefaultFractal gives continents with a moderate amount of detail.
+var DefaultFractal = Fractal{Octaves: 6, Frequency: 4, Lacunarity: 2, Persistence: .5}
+
+// fractalNoise is a Fractal with its seeded GradientNoise for each octave.
+type fractalNoise struct {
+	Fractal
+	octaves []*GradientNoise
+}
+
+// newFractalNoise seeds a GradientNoise for each octave of the Fractal.
+func newFractalNoise(d Dice, f Fractal) *fractalNoise {
+	n := &fractalNoise{f, make([]*GradientNoise, f.Octaves)}
+	for i := range n.octaves {
+		n.octaves[i] = NewGradientNoiseWith(d)
+	}
+	return n
+}
+
+// sample computes the fractal noise at a cell of a map which is cols wide. If
+// wrap is set, the frequency of each octave is rounded to a whole number of
+// lattice cells, so that the noise wraps around the map seamlessly.
+func (n *fractalNoise) sample(x, y float64, cols int, wrap bool) float64 {
+	total, amplitude, frequency := 0.0, 1.0, n.Frequency
+	for _, octave := range n.octaves {
+		cells, period := frequency, 0
+		if wrap {
+			period = Max(1, int(math.Floor(frequency+.5)))
+			cells = float64(period)
+		}
+		scale := cells / float64(cols)
+
+		value := octave.Noise(x*scale, y*scale, period)
+		if n.Ridged {
+			value = 1 - math.Abs(value)
+			value *= value
+		}
+		total += amplitude * value
+
+		amplitude *= n.Persistence
+		frequency *= n.Lacunarity
+	}
+	return total
+}
+
+// RaiseFractal adds fractal noise to the Heightmap as with RaiseFractalWith,
+// using the global Dice.
+func (h *Heightmap) RaiseFractal(f Fractal) {
+	h.RaiseFractalWith(globalDice, f)
+}
+
+// RaiseFractalWith adds fractal noise, as specified by the Fractal, to each
+// value of the Heightmap, using the given Dice to seed the noise. The noise
+// wraps around the x-axis if WrapX is true. The result is not normalized, so
+// it can be combined with other raises before calling Equalize or Normalize.
+func (h *Heightmap) RaiseFractalWith(d Dice, f Fractal) {
+	height := newFractalNoise(d, f)
+
+	var warpX, warpY *fractalNoise
+	if f.Warp != 0 {
+		warp := f
+		warp.Ridged, warp.Warp = false, 0
+		warpX, warpY = newFractalNoise(d, warp), newFractalNoise(d, warp)
+	}
+
+	// the warp is given in lattice cells of the first octave, so convert it to
+	// cells of the map
+	warpScale := f.Warp * float64(h.cols) / math.Max(f.Frequency, 1)
+
+	for x := 0; x < h.cols; x++ {
+		for y := 0; y < h.rows; y++ {
+			h.buf[x][y] += h.sampleFractal(height, warpX, warpY, warpScale, float64(x), float64(y))
+		}
+	}
+}
+
+// sampleFractal samples the fractal noise at a point, after displacing the
+// point by the warp noise if there is any.
+func (h *Heightmap) sampleFractal(height, warpX, warpY *fractalNoise, warpScale, x, y float64) float64 {
+	if warpX != nil {
+		x, y = x+warpScale*warpX.sample(x, y, h.cols, h.WrapX), y+warpScale*warpY.sample(x, y, h.cols, h.WrapX)
+	}
+	return height.sample(x, y, h.cols, h.WrapX)
+}
+
+// GenerateFractal performs the full heightmap generation process as with
+// GenerateFractalWith, using the global Dice.
+func (h *Heightmap) GenerateFractal(f Fractal) {
+	h.GenerateFractalWith(globalDice, f)
+}
+
+// GenerateFractalWith performs the full heightmap generation process using
+// fractal noise instead of ellipses, making every random choice with the given
+// Dice. Unlike Generate, the cost does not depend on NumEllipses, RadiusX and
+// RadiusY, and the terrain is less blobby.
+func (h *Heightmap) GenerateFractalWith(d Dice, f Fractal) {
+	h.Reset()
+	h.RaiseFractalWith(d, f)
+	h.Equalize()
+	h.Normalize()
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGradientNoiseLattice(t *testing.T) {
+	n := NewGradientNoiseWith(NewDice(newXorshift(1)))
+	for x := -3; x < 3; x++ {
+		for y := -3; y < 3; y++ {
+			if v := n.Noise(float64(x), float64(y), 0); v != 0 {
+				t.Errorf("Noise(%d, %d) = %v, expected 0", x, y, v)
+			}
+		}
+	}
+
+	// the noise should repeat with the period, but not otherwise
+	same, differ := true, false
+	for i := 0; i < 20; i++ {
+		x, y := float64(i)*.37, float64(i)*.61
+		if math.Abs(n.Noise(x, y, 3)-n.Noise(x+3, y, 3)) > 1e-12 {
+			same = false
+		}
+		if n.Noise(x, y, 0) != n.Noise(x+3, y, 0) {
+			differ = true
+		}
+	}
+	if !same || !differ {
+		t.Errorf("Noise period not respected")
+	}
+}
+
+func TestRaiseFractalDeterministic(t *testing.T) {
+	a, b := NewHeightmap(40, 20), NewHeightmap(40, 20)
+	f := DefaultFractal
+	f.Warp = .5
+	a.GenerateFractalWith(NewDice(newXorshift(1)), f)
+	b.GenerateFractalWith(NewDice(newXorshift(1)), f)
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			if a.Read(x, y) != b.Read(x, y) {
+				t.Fatalf("GenerateFractal was not deterministic at %d, %d", x, y)
+			}
+			if v := a.Read(x, y); v < 0 || v > 1 {
+				t.Fatalf("GenerateFractal gave %v outside [0, 1]", v)
+			}
+		}
+	}
+}
+
+func TestRaiseFractalWrap(t *testing.T) {
+	cases := []Fractal{
+		DefaultFractal,
+		{Octaves: 4, Frequency: 3.3, Lacunarity: 2.1, Persistence: .6, Ridged: true},
+		{Octaves: 3, Frequency: 2, Lacunarity: 2, Persistence: .5, Warp: 1},
+	}
+	for i, f := range cases {
+		h := NewHeightmap(64, 32)
+		d := NewDice(newXorshift(1))
+		height := newFractalNoise(d, f)
+		warp := f
+		warp.Ridged, warp.Warp = false, 0
+		warpX, warpY := newFractalNoise(d, warp), newFractalNoise(d, warp)
+		scale := f.Warp * 64 / f.Frequency
+
+		// sampling one full map width past a cell should give the same value
+		for y := 0; y < 32; y++ {
+			v0 := h.sampleFractal(height, warpX, warpY, scale, 0, float64(y))
+			v1 := h.sampleFractal(height, warpX, warpY, scale, 64, float64(y))
+			if math.Abs(v0-v1) > 1e-9 {
+				t.Errorf("case %d did not wrap at row %d: %v != %v", i, y, v0, v1)
+				break
+			}
+		}
+	}
+}
+
+func TestRaiseFractalRidged(t *testing.T) {
+	h := NewHeightmap(32, 32)
+	h.RaiseFractalWith(NewDice(newXorshift(1)), Fractal{Octaves: 1, Frequency: 4, Ridged: true})
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			if v := h.Read(x, y); v < 0 || v > 1 {
+				t.Fatalf("ridged noise gave %v outside [0, 1]", v)
+			}
+		}
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	h := NewHeightmap(200, 100)
+	for i := 0; i < b.N; i++ {
+		h.Generate()
+	}
+}
+
+func BenchmarkGenerateFractal(b *testing.B) {
+	h := NewHeightmap(200, 100)
+	d := NewDice(newXorshift(1))
+	for i := 0; i < b.N; i++ {
+		h.GenerateFractalWith(d, DefaultFractal)
+	}
+}
@@ -63,12 +63,25 @@ func (s *scorer) Path(t *Tile) []*Tile {
 	return append(s.Path(prev), t)
 }
 
+// tileentry is a Tile in a tilequeue, along with its FScore when queued. The
+// score is copied so that improving the score of a queued Tile does not break
+// the heap ordering; the improved Tile is simply queued again.
+type tileentry struct {
+	tile   *Tile
+	fscore float64
+}
+
 // tilequeue implements heap.Interface, using the FScore to sort.
 type tilequeue struct {
-	queue  []*Tile
+	queue  []tileentry
 	scores *scorer
 }
 
+// newtilequeue creates a tilequeue containing only the origin.
+func newtilequeue(origin *Tile, scores *scorer) *tilequeue {
+	return &tilequeue{[]tileentry{{origin, scores.Score(origin).FScore()}}, scores}
+}
+
 // Len returns the number of Tiles in the queue.
 func (q *tilequeue) Len() int {
 	return len(q.queue)
@@ -77,7 +90,7 @@ func (q *tilequeue) Len() int {
 // Less compares the FScore of the ith and jth Tiles, and returns true if the
 // score for the ith Tile is less than that of the jth Tile.
 func (q *tilequeue) Less(i, j int) bool {
-	return q.scores.Score(q.queue[i]).FScore() < q.scores.Score(q.queue[j]).FScore()
+	return q.queue[i].fscore < q.queue[j].fscore
 }
 
 // Swap switches the values of the ith and jth Tile in the queue.
@@ -87,7 +100,8 @@ func (q *tilequeue) Swap(i, j int) {
 
 // Push pushes a *Tile onto the queue, panicing if the data is not a *Tile.
 func (q *tilequeue) Push(x interface{}) {
-	q.queue = append(q.queue, x.(*Tile))
+	t := x.(*Tile)
+	q.queue = append(q.queue, tileentry{t, q.scores.Score(t).FScore()})
 }
 
 // Pop removes and returns the last *Tile in the queue as an interface{}.
@@ -95,17 +109,56 @@ func (q *tilequeue) Pop() interface{} {
 	n := len(q.queue) - 1
 	x := q.queue[n]
 	q.queue = q.queue[:n]
-	return x
+	return x.tile
 }
 
 // GraphSearch performs a generic graph search from the origin to the goal
 // using the given heuristic and cost. If the heuristic is admissible, meaning
 // it never underestimates the final path cost, then the resulting path will be
-// optimal with respect to cost.
+// optimal with respect to cost. The search follows Stairs as well as Adjacent
+// Tile, so the path may change Levels. Such a step has the same Offset before
+// and after, and should be taken with a MoveEntity using Climb.
 func GraphSearch(origin, goal *Tile, cost, heuristic DistFn) []*Tile {
+	return Walking.GraphSearch(origin, goal, cost, heuristic)
+}
+
+// GraphSearch performs a generic graph search from the origin to the goal for
+// an Entity using the Movement. The cost of each step is given by the cost
+// DistFn scaled by the Movement cost of the Tile being stepped into.
+func (m Movement) GraphSearch(origin, goal *Tile, cost, heuristic DistFn) []*Tile {
+	if m == nil {
+		m = Walking
+	}
+	if path, ok := m.LimitedSearch(origin, goal, cost, heuristic, SearchLimit{}); ok {
+		return path
+	}
+	return nil
+}
+
+// SearchLimit bounds the effort spent by LimitedSearch. MaxCost is the largest
+// path cost which will be explored, and MaxNodes is the number of Tiles which
+// will be explored before giving up. A zero value means no limit.
+type SearchLimit struct {
+	MaxCost  float64
+	MaxNodes int
+}
+
+// LimitedSearch performs a graph search for an Entity using the Movement, as
+// with GraphSearch, except that the effort of the search is bounded by the
+// SearchLimit. If the goal is found, the path is returned with ok set to true.
+// Otherwise, the best effort path is returned with ok set to false, leading to
+// the explored Tile with the lowest heuristic to the goal, which is typically
+// the Tile closest to the goal. This allows a creature chasing prey across
+// water to at least reach the shore. If no explored Tile is closer to the goal
+// than the origin, the best effort path is empty.
+func (m Movement) LimitedSearch(origin, goal *Tile, cost, heuristic DistFn, limit SearchLimit) (path []*Tile, ok bool) {
+	if m == nil {
+		m = Walking
+	}
 	scores := newscorer(origin, goal, heuristic)
-	frontier := &tilequeue{[]*Tile{origin}, scores}
+	frontier := newtilequeue(origin, scores)
 	closed := make(map[*Tile]struct{})
+	best := origin
 
 	for frontier.Len() > 0 {
 		// get the next tile to explore, skip if we've already closed it
@@ -114,24 +167,37 @@ func GraphSearch(origin, goal *Tile, cost, heuristic DistFn) []*Tile {
 			continue
 		}
 
+		// if we've explored our budget of Tiles, settle for a partial path
+		if limit.MaxNodes > 0 && len(closed) >= limit.MaxNodes {
+			break
+		}
+
 		// mark current as seen
 		closed[curr] = struct{}{}
 
 		// if we find the goal, we've already found the best path
 		if curr == goal {
-			return scores.Path(goal)
+			return scores.Path(goal), true
+		}
+
+		// keep track of the explored Tile closest to the goal
+		currscore, bestscore := scores.Score(curr), scores.Score(best)
+		if currscore.HEst < bestscore.HEst || currscore.HEst == bestscore.HEst && currscore.GCost < bestscore.GCost {
+			best = curr
 		}
 
 		// for each neighbor, see if we've found a better path, then enqueue it
-		currscore := scores.Score(curr)
-		for _, adj := range curr.Adjacent {
-			if !adj.Pass {
-				continue
+		expand := func(adj *Tile) {
+			if !m.Passable(adj) {
+				return
 			}
 
 			if _, seen := closed[adj]; !seen {
 				// compute the cost of that path to adj going through curr
-				cost := currscore.GCost + cost(curr, adj)
+				cost := currscore.GCost + cost(curr, adj)*m(adj)
+				if limit.MaxCost > 0 && cost > limit.MaxCost {
+					return
+				}
 
 				// we found a better path for the adjacent tile
 				if adjscore := scores.Score(adj); cost < adjscore.GCost {
@@ -141,10 +207,24 @@ func GraphSearch(origin, goal *Tile, cost, heuristic DistFn) []*Tile {
 				}
 			}
 		}
+		for _, adj := range curr.Adjacent {
+			expand(adj)
+		}
+		for _, adj := range curr.Stairs {
+			expand(adj)
+		}
 	}
 
-	// if we exhaust the frontier, and didn't find the goal, there is no path
-	return nil
+	// if we exhaust the frontier, and didn't find the goal, settle for the
+	// path to the closest Tile we found
+	return scores.Path(best), false
+}
+
+// PartialPath computes a minimum cost path between two Tiles, as with
+// AStarPath, except that the search is bounded by the SearchLimit and a best
+// effort path is returned if the goal cannot be reached.
+func PartialPath(origin, goal *Tile, limit SearchLimit) (path []*Tile, ok bool) {
+	return Walking.LimitedSearch(origin, goal, euclidean, euclidean, limit)
 }
 
 // NewGraphSearch creates a GraphSearch function with the given DistFns.
@@ -160,8 +240,10 @@ func NewGraphSearch(cost, heuristic DistFn) func(*Tile, *Tile) []*Tile {
 const tiebreak = 1 + 1e-10
 
 // euclidean computes the tiebreak version of Euclidean distance between nodes.
+// Each change in Level adds 1 to the distance.
 func euclidean(a, b *Tile) float64 {
-	return b.Offset.Sub(a.Offset).Euclidean() * tiebreak
+	levels := float64(Abs(b.Level - a.Level))
+	return (b.Offset.Sub(a.Offset).Euclidean() + levels) * tiebreak
 }
 
 // zero is a DistFn which simply returns 0.
@@ -174,7 +256,195 @@ func AStarPath(origin, goal *Tile) []*Tile {
 	return GraphSearch(origin, goal, euclidean, euclidean)
 }
 
+// AStarPath computes a minimum cost path between two Tiles for an Entity using
+// the Movement. The euclidean heuristic assumes that no step costs less than
+// its length, so the path is only guaranteed to be minimal if every Movement
+// cost is at least 1.
+func (m Movement) AStarPath(origin, goal *Tile) []*Tile {
+	return m.GraphSearch(origin, goal, euclidean, euclidean)
+}
+
 // GreedyPath computes a greedy path between two Tiles.
 func GreedyPath(origin, goal *Tile) []*Tile {
 	return GraphSearch(origin, goal, zero, euclidean)
 }
+
+// OccupantCost wraps a DistFn so that stepping onto an occupied Tile costs an
+// additional penalty. A small penalty encourages paths to go around other
+// Entities when a detour is cheap, while a penalty of math.Inf(1) treats every
+// occupied Tile as impassable.
+func OccupantCost(cost DistFn, penalty float64) DistFn {
+	return func(a, b *Tile) float64 {
+		if b.Occupant != nil {
+			return cost(a, b) + penalty
+		}
+		return cost(a, b)
+	}
+}
+
+// OccupantPath computes a minimum cost path between two Tiles, with occupied
+// Tiles costing an additional penalty as with OccupantCost. The goal is exempt
+// from the penalty, so that an occupied goal, such as the position of prey,
+// can still be reached.
+func OccupantPath(origin, goal *Tile, penalty float64) []*Tile {
+	occupied := OccupantCost(euclidean, penalty)
+	cost := func(a, b *Tile) float64 {
+		if b == goal {
+			return euclidean(a, b)
+		}
+		return occupied(a, b)
+	}
+	return GraphSearch(origin, goal, cost, euclidean)
+}
+
+// JumpPointPath computes a minimum cost path between two Tiles using jump point
+// search. Jump point search is only valid for uniform-cost 8-connected grids,
+// such as those created by NewTileGrid, in which case the resulting path will
+// have the same cost as one from AStarPath. By skipping over the symmetric
+// paths found in open terrain, jump point search typically explores far fewer
+// Tiles than AStarPath on wide-open maps such as an overworld.
+func JumpPointPath(origin, goal *Tile) []*Tile {
+	scores := newscorer(origin, goal, euclidean)
+	frontier := newtilequeue(origin, scores)
+	closed := make(map[*Tile]struct{})
+
+	for frontier.Len() > 0 {
+		// get the next jump point to explore, skip if we've already closed it
+		curr := heap.Pop(frontier).(*Tile)
+		if _, seen := closed[curr]; seen {
+			continue
+		}
+		closed[curr] = struct{}{}
+
+		// if we find the goal, fill in the Tiles between the jump points
+		if curr == goal {
+			return expandJumps(origin, scores.Path(goal))
+		}
+
+		// for each pruned direction, jump to the next jump point and see if
+		// we've found a better path to it.
+		currscore := scores.Score(curr)
+		for _, dir := range jumpDirections(curr, currscore.Prev) {
+			jump := jumpFrom(curr, dir, goal)
+			if jump == nil {
+				continue
+			}
+			if _, seen := closed[jump]; !seen {
+				cost := currscore.GCost + euclidean(curr, jump)
+				if jumpscore := scores.Score(jump); cost < jumpscore.GCost {
+					jumpscore.GCost = cost
+					jumpscore.Prev = curr
+					heap.Push(frontier, jump)
+				}
+			}
+		}
+	}
+
+	// if we exhaust the frontier, and didn't find the goal, there is no path
+	return nil
+}
+
+// jumpOpen returns the neighbor of a Tile in a direction if it is passable.
+func jumpOpen(t *Tile, dx, dy int) *Tile {
+	if adj, ok := t.Adjacent[Offset{dx, dy}]; ok && adj.Pass {
+		return adj
+	}
+	return nil
+}
+
+// jumpForced returns true if the Tile has a forced neighbor when entered by
+// travelling in the given direction, meaning an obstacle prevents some
+// neighbor from being optimally reached without going through the Tile.
+func jumpForced(t *Tile, d Offset) bool {
+	blocked := func(dx, dy int) bool { return jumpOpen(t, dx, dy) == nil }
+	open := func(dx, dy int) bool { return jumpOpen(t, dx, dy) != nil }
+
+	switch {
+	case d.X != 0 && d.Y != 0:
+		return blocked(-d.X, 0) && open(-d.X, d.Y) || blocked(0, -d.Y) && open(d.X, -d.Y)
+	case d.X != 0:
+		return blocked(0, 1) && open(d.X, 1) || blocked(0, -1) && open(d.X, -1)
+	default:
+		return blocked(1, 0) && open(1, d.Y) || blocked(-1, 0) && open(-1, d.Y)
+	}
+}
+
+// jumpDirections gives the directions worth exploring from a jump point, after
+// pruning the neighbors which are better reached without going through it.
+func jumpDirections(t, prev *Tile) []Offset {
+	if prev == nil {
+		return cardinal[:]
+	}
+
+	delta := t.Offset.Sub(prev.Offset)
+	d := Offset{Signum(delta.X), Signum(delta.Y)}
+	blocked := func(dx, dy int) bool { return jumpOpen(t, dx, dy) == nil }
+
+	var dirs []Offset
+	switch {
+	case d.X != 0 && d.Y != 0:
+		dirs = append(dirs, d, Offset{d.X, 0}, Offset{0, d.Y})
+		if blocked(-d.X, 0) {
+			dirs = append(dirs, Offset{-d.X, d.Y})
+		}
+		if blocked(0, -d.Y) {
+			dirs = append(dirs, Offset{d.X, -d.Y})
+		}
+	case d.X != 0:
+		dirs = append(dirs, d)
+		if blocked(0, 1) {
+			dirs = append(dirs, Offset{d.X, 1})
+		}
+		if blocked(0, -1) {
+			dirs = append(dirs, Offset{d.X, -1})
+		}
+	default:
+		dirs = append(dirs, d)
+		if blocked(1, 0) {
+			dirs = append(dirs, Offset{1, d.Y})
+		}
+		if blocked(-1, 0) {
+			dirs = append(dirs, Offset{-1, d.Y})
+		}
+	}
+	return dirs
+}
+
+// jumpFrom travels from a Tile in the given direction until reaching a jump
+// point, which is either the goal, a Tile with a forced neighbor, or (when
+// travelling diagonally) a Tile from which a straight jump finds a jump point.
+// If travel is obstructed before reaching a jump point, nil is returned.
+func jumpFrom(t *Tile, d Offset, goal *Tile) *Tile {
+	start := t
+	for {
+		next := jumpOpen(t, d.X, d.Y)
+		// stop if obstructed, or if the map wraps around back to the start
+		if next == nil || next == start {
+			return nil
+		}
+		if next == goal || jumpForced(next, d) {
+			return next
+		}
+		if d.X != 0 && d.Y != 0 {
+			if jumpFrom(next, Offset{d.X, 0}, goal) != nil || jumpFrom(next, Offset{0, d.Y}, goal) != nil {
+				return next
+			}
+		}
+		t = next
+	}
+}
+
+// expandJumps fills in the Tiles between each jump point in a path.
+func expandJumps(origin *Tile, jumps []*Tile) []*Tile {
+	var path []*Tile
+	curr := origin
+	for _, jump := range jumps {
+		delta := jump.Offset.Sub(curr.Offset)
+		d := Offset{Signum(delta.X), Signum(delta.Y)}
+		for curr != jump {
+			curr = curr.Adjacent[d]
+			path = append(path, curr)
+		}
+	}
+	return path
+}
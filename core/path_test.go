@@ -195,3 +195,267 @@ func TestCustomSearch(t *testing.T) {
 		RunCase(t, "GraphSearch", i, search, c)
 	}
 }
+
+func TestOccupantPath(t *testing.T) {
+	cases := []struct {
+		grid    StrGrid
+		penalty float64
+	}{
+		{StrGrid{
+			"#######",
+			"#.xxx.#",
+			"#x###x#",
+			"#@.o.$#",
+			"#######",
+		}, math.Inf(1)},
+		{StrGrid{
+			"#######",
+			"#.....#",
+			"#.#o#.#",
+			"#@xxx$#",
+			"#######",
+		}, 1},
+		{StrGrid{
+			"#######",
+			"#.#.#.#",
+			"#@xxxO#",
+			"#######",
+		}, math.Inf(1)},
+		{StrGrid{
+			"#######",
+			"#.###.#",
+			"#@.o.$#",
+			"#######",
+		}, math.Inf(1)},
+	}
+	for i, c := range cases {
+		var origin, goal *Tile
+		expected := make(map[*Tile]struct{})
+		c.grid.Convert(func(t *Tile, ch byte) {
+			switch ch {
+			case '#':
+				t.Pass = false
+			case '@':
+				origin = t
+			case 'o':
+				t.Occupant = &posRecorder{t}
+			case 'O':
+				t.Occupant = &posRecorder{t}
+				fallthrough
+			case '$':
+				goal = t
+				fallthrough
+			case 'x':
+				expected[t] = struct{}{}
+			}
+		})
+		if i == 3 {
+			expected = nil
+		}
+
+		actual := OccupantPath(origin, goal, c.penalty)
+		if !PathValid(actual) || !PathsEqual(actual, expected) {
+			t.Errorf("OccupantPath failed case %d", i)
+		}
+	}
+}
+
+func TestAStarPathStairs(t *testing.T) {
+	var origin, goal, upStair, downStair *Tile
+	StrGrid{
+		"#####",
+		"#@.>#",
+		"#####",
+	}.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#':
+			t.Pass = false
+		case '@':
+			origin = t
+		case '>':
+			downStair = t
+		}
+	})
+	StrGrid{
+		"#####",
+		"#$.<#",
+		"#####",
+	}.Convert(func(t *Tile, c byte) {
+		t.Level = 1
+		switch c {
+		case '#':
+			t.Pass = false
+		case '$':
+			goal = t
+		case '<':
+			upStair = t
+		}
+	})
+
+	if path := AStarPath(origin, goal); path != nil {
+		t.Errorf("AStarPath found path between unlinked Levels")
+	}
+
+	LinkStairs(downStair, upStair)
+	path := AStarPath(origin, goal)
+	if len(path) != 5 || path[1] != downStair || path[2] != upStair || path[4] != goal {
+		t.Errorf("AStarPath did not take the Stairs")
+	}
+}
+
+// PathCost computes the sum of the Euclidean step lengths along a path.
+func PathCost(origin *Tile, path []*Tile) float64 {
+	cost, prev := 0.0, origin
+	for _, t := range path {
+		cost += t.Offset.Sub(prev.Offset).Euclidean()
+		prev = t
+	}
+	return cost
+}
+
+func TestJumpPointPath(t *testing.T) {
+	cases := []StrGrid{
+		{
+			"#######",
+			"#$....#",
+			"#.....#",
+			"#.....#",
+			"#.....#",
+			"#....@#",
+			"#######",
+		}, {
+			"#######",
+			"#$....#",
+			"#######",
+			"#.....#",
+			"#.....#",
+			"#....@#",
+			"#######",
+		}, {
+			"########",
+			"#$.....#",
+			"#####..#",
+			"#......#",
+			"#...####",
+			"#.....@#",
+			"########",
+		}, {
+			"###########",
+			"#....$....#",
+			"#.#######.#",
+			"#.###...#.#",
+			"#.###.#.#.#",
+			"#.###.#.#.#",
+			"#.###.#.#.#",
+			"#.###@#.#.#",
+			"#.....#...#",
+			"###########",
+		},
+	}
+	for i, c := range cases {
+		origin, goal, _ := SearchCase(c)
+		expected, actual := AStarPath(origin, goal), JumpPointPath(origin, goal)
+		if (expected == nil) != (actual == nil) {
+			t.Errorf("JumpPointPath case %d disagreed on reachability", i)
+			continue
+		}
+		if !PathValid(append([]*Tile{origin}, actual...)) {
+			t.Errorf("JumpPointPath case %d gave invalid path", i)
+		}
+		if math.Abs(PathCost(origin, expected)-PathCost(origin, actual)) > 1e-9 {
+			t.Errorf("JumpPointPath case %d cost %f != %f", i, PathCost(origin, actual), PathCost(origin, expected))
+		}
+	}
+}
+
+func TestJumpPointPathRandom(t *testing.T) {
+	dice := NewDice(newXorshift(1))
+	for i := 0; i < 20; i++ {
+		tiles := NewTileGrid(40, 30, Offset{}, func(o Offset) *Tile {
+			t := NewTile(o)
+			t.Pass = !dice.Chance(.3)
+			return t
+		})
+		origin, goal := dice.PassTile(tiles), dice.PassTile(tiles)
+		expected, actual := AStarPath(origin, goal), JumpPointPath(origin, goal)
+		if (expected == nil) != (actual == nil) {
+			t.Errorf("JumpPointPath disagreed on reachability")
+			continue
+		}
+		if !PathValid(append([]*Tile{origin}, actual...)) || (len(actual) > 0 && actual[len(actual)-1] != goal) {
+			t.Errorf("JumpPointPath gave invalid path")
+		}
+		if math.Abs(PathCost(origin, expected)-PathCost(origin, actual)) > 1e-9 {
+			t.Errorf("JumpPointPath cost %f != %f", PathCost(origin, actual), PathCost(origin, expected))
+		}
+	}
+}
+
+func BenchmarkAStarPathOpen(b *testing.B) {
+	tiles := NewTileGrid(200, 400, Offset{}, NewTile)
+	origin, goal := tiles[0], tiles[len(tiles)-1]
+	for i := 0; i < b.N; i++ {
+		AStarPath(origin, goal)
+	}
+}
+
+func BenchmarkJumpPointPathOpen(b *testing.B) {
+	tiles := NewTileGrid(200, 400, Offset{}, NewTile)
+	origin, goal := tiles[0], tiles[len(tiles)-1]
+	for i := 0; i < b.N; i++ {
+		JumpPointPath(origin, goal)
+	}
+}
+
+func TestPartialPath(t *testing.T) {
+	cases := []struct {
+		grid  StrGrid
+		limit SearchLimit
+		ok    bool
+	}{
+		{StrGrid{
+			"#########",
+			"#@xxx#.$#",
+			"#########",
+		}, SearchLimit{}, false},
+		{StrGrid{
+			"#########",
+			"#@xxxxx$#",
+			"#########",
+		}, SearchLimit{}, true},
+		{StrGrid{
+			"#########",
+			"#@xxx...$",
+			"#########",
+		}, SearchLimit{MaxCost: 3.5}, false},
+		{StrGrid{
+			"#########",
+			"#@xx....$",
+			"#########",
+		}, SearchLimit{MaxNodes: 3}, false},
+	}
+	for i, c := range cases {
+		var origin, goal *Tile
+		expected := make(map[*Tile]struct{})
+		c.grid.Convert(func(t *Tile, ch byte) {
+			switch ch {
+			case '#':
+				t.Pass = false
+			case '@':
+				origin = t
+			case '$':
+				goal = t
+				if c.ok {
+					expected[t] = struct{}{}
+				}
+			case 'x':
+				expected[t] = struct{}{}
+			}
+		})
+
+		actual, ok := PartialPath(origin, goal, c.limit)
+		if ok != c.ok || !PathValid(actual) || !PathsEqual(actual, expected) {
+			t.Errorf("PartialPath failed case %d", i)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package core
+
+// Prefab is a hand-designed piece of map, such as a vault, shrine or camp,
+// which can be stamped into a generated map. A Prefab is made from an ASCII
+// template, with a legend giving the kind of cell for each character, such as
+// TileTypeWall, so that the Tiles are created with a MapGenInt just like those
+// of a Dungeon. Spaces in the template are transparent, leaving the existing
+// map as it was. Every other character is remembered when the Prefab is
+// stamped, so that markers, such as a spawn point or an item, can be given the
+// kind of the floor beneath them and then found after stamping.
+type Prefab struct {
+	cols, rows int
+	kinds      [][]int
+	chars      [][]byte
+}
+
+// DefaultPrefabLegend gives walls as '#', floor as '.' and doors as '+'.
+var DefaultPrefabLegend = map[byte]int{
+	'#': TileTypeWall,
+	'.': TileTypeRoom,
+	'+': TileTypeDoor,
+}
+
+// NewPrefab creates a Prefab from an ASCII template, with one string per row,
+// using the legend to give the kind of each character. If the rows are not all
+// the same length, ErrPrefabShape is returned, and if a character other than a
+// space is missing from the legend, ErrPrefabLegend is returned. Kind 0 is used
+// for transparent cells, so the legend should not give any character kind 0.
+func NewPrefab(template []string, legend map[byte]int) (*Prefab, error) {
+	if len(template) == 0 || len(template[0]) == 0 {
+		return nil, ErrPrefabShape
+	}
+
+	p := newPrefab(len(template[0]), len(template))
+	for y, line := range template {
+		if len(line) != p.cols {
+			return nil, ErrPrefabShape
+		}
+		for x := 0; x < p.cols; x++ {
+			ch := line[x] // care - the template is indexed y, x
+			if ch == ' ' {
+				continue
+			}
+			kind, ok := legend[ch]
+			if !ok {
+				return nil, ErrPrefabLegend
+			}
+			p.kinds[x][y], p.chars[x][y] = kind, ch
+		}
+	}
+	return p, nil
+}
+
+// MustPrefab creates a Prefab as with NewPrefab, but panics if the template is
+// invalid. It is intended for templates which are compiled into the game.
+func MustPrefab(template []string, legend map[byte]int) *Prefab {
+	p, err := NewPrefab(template, legend)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// newPrefab creates an empty Prefab with the given dimensions.
+func newPrefab(cols, rows int) *Prefab {
+	p := &Prefab{cols: cols, rows: rows}
+	p.kinds = make([][]int, cols)
+	p.chars = make([][]byte, cols)
+	for x := 0; x < cols; x++ {
+		p.kinds[x] = make([]int, rows)
+		p.chars[x] = make([]byte, rows)
+	}
+	return p
+}
+
+// Cols returns the width of the Prefab.
+func (p *Prefab) Cols() int {
+	return p.cols
+}
+
+// Rows returns the height of the Prefab.
+func (p *Prefab) Rows() int {
+	return p.rows
+}
+
+// Rotate returns a copy of the Prefab rotated a quarter turn clockwise.
+func (p *Prefab) Rotate() *Prefab {
+	r := newPrefab(p.rows, p.cols)
+	for x := 0; x < p.cols; x++ {
+		for y := 0; y < p.rows; y++ {
+			r.kinds[p.rows-1-y][x] = p.kinds[x][y]
+			r.chars[p.rows-1-y][x] = p.chars[x][y]
+		}
+	}
+	return r
+}
+
+// Mirror returns a copy of the Prefab flipped left to right.
+func (p *Prefab) Mirror() *Prefab {
+	m := newPrefab(p.cols, p.rows)
+	for x := 0; x < p.cols; x++ {
+		copy(m.kinds[p.cols-1-x], p.kinds[x])
+		copy(m.chars[p.cols-1-x], p.chars[x])
+	}
+	return m
+}
+
+// Orient returns a copy of the Prefab rotated clockwise by the given number of
+// quarter turns, and then mirrored if mirror is true.
+func (p *Prefab) Orient(turns int, mirror bool) *Prefab {
+	o := p
+	for i := 0; i < Mod(turns, 4); i++ {
+		o = o.Rotate()
+	}
+	if mirror {
+		o = o.Mirror()
+	}
+	return o
+}
+
+// RandOrient returns a copy of the Prefab in one of its eight orientations,
+// chosen at random with the global Dice.
+func (p *Prefab) RandOrient() *Prefab {
+	return p.RandOrientWith(globalDice)
+}
+
+// RandOrientWith returns a copy of the Prefab in one of its eight orientations,
+// chosen at random with the given Dice.
+func (p *Prefab) RandOrientWith(d Dice) *Prefab {
+	return p.Orient(d.Intn(4), d.Bool())
+}
+
+// Stamp places the Prefab into an existing map, with the top-left corner of the
+// Prefab at the site. Each Tile under a non-transparent cell of the Prefab
+// takes the Face, Pass and Lite of a Tile created by the MapGenInt, and is
+// linked to every neighboring Tile, so that the Prefab is connected to the
+// surrounding map even where it opens up a wall. Only Tiles on the base Level
+// of the map are stamped. If any cell of the Prefab has no Tile beneath it,
+// ErrNoSite is returned and the map is not changed. Otherwise, the stamped
+// Tiles are returned grouped by their character in the template, so that any
+// markers can be found.
+func (p *Prefab) Stamp(tiles []*Tile, site Offset, f MapGenInt) (map[byte][]*Tile, error) {
+	if len(tiles) == 0 {
+		return nil, ErrNoSite
+	}
+	index := levelIndex(tiles, tiles[0].Level)
+
+	// check that the Prefab fits before changing anything
+	for x := 0; x < p.cols; x++ {
+		for y := 0; y < p.rows; y++ {
+			if _, ok := index[site.Add(Offset{x, y})]; p.kinds[x][y] != 0 && !ok {
+				return nil, ErrNoSite
+			}
+		}
+	}
+
+	marks := make(map[byte][]*Tile)
+	for x := 0; x < p.cols; x++ {
+		for y := 0; y < p.rows; y++ {
+			if p.kinds[x][y] == 0 {
+				continue
+			}
+			o := site.Add(Offset{x, y})
+			tile, made := index[o], f(o, p.kinds[x][y])
+			tile.Face, tile.Pass, tile.Lite = made.Face, made.Pass, made.Lite
+			for _, step := range cardinal {
+				if adj, ok := index[o.Add(step)]; ok {
+					tile.Adjacent[step] = adj
+					adj.Adjacent[step.Neg()] = tile
+				}
+			}
+			marks[p.chars[x][y]] = append(marks[p.chars[x][y]], tile)
+		}
+	}
+	return marks, nil
+}
+
+// Place finds a site for the Prefab and stamps it there, as with PlaceWith,
+// using the global Dice to find the site.
+func (p *Prefab) Place(tiles []*Tile, accept func([]*Tile) bool, f MapGenInt) (map[byte][]*Tile, error) {
+	return p.PlaceWith(globalDice, tiles, accept, f)
+}
+
+// PlaceWith finds a site for the Prefab using FindSite with the given Dice, and
+// then stamps the Prefab there as with Stamp. The accept function decides
+// whether a site is suitable, such as Clearing for the middle of a Dungeon
+// room, and may be nil to accept any site. If no site is accepted, ErrNoSite
+// is returned.
+func (p *Prefab) PlaceWith(d Dice, tiles []*Tile, accept func([]*Tile) bool, f MapGenInt) (map[byte][]*Tile, error) {
+	if accept == nil {
+		accept = func([]*Tile) bool { return true }
+	}
+	site, ok := FindSiteWith(d, tiles, p.cols, p.rows, accept)
+	if !ok {
+		return nil, ErrNoSite
+	}
+	return p.Stamp(tiles, site, f)
+}
+
+// Clearing accepts a footprint in which every Tile, along with each of its
+// neighbors, is passable. In a Dungeon, this means the footprint lies inside a
+// room without touching its walls or doors, and in an overworld it means an
+// open field.
+func Clearing(footprint []*Tile) bool {
+	for _, tile := range footprint {
+		if !tile.Pass || len(tile.Adjacent) < len(cardinal) {
+			return false
+		}
+		for _, adj := range tile.Adjacent {
+			if !adj.Pass {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// prefabLines renders a Prefab back into its template.
+func prefabLines(p *Prefab) []string {
+	lines := make([]string, p.Rows())
+	for y := range lines {
+		line := make([]byte, p.Cols())
+		for x := range line {
+			line[x] = ' '
+			if p.kinds[x][y] != 0 {
+				line[x] = p.chars[x][y]
+			}
+		}
+		lines[y] = string(line)
+	}
+	return lines
+}
+
+// shrineLegend adds an altar marker to the DefaultPrefabLegend.
+var shrineLegend = map[byte]int{'#': TileTypeWall, '.': TileTypeRoom, '+': TileTypeDoor, '_': TileTypeRoom}
+
+func TestNewPrefab(t *testing.T) {
+	cases := []struct {
+		template []string
+		err      error
+	}{
+		{[]string{"#+#", "#_#", " # "}, nil},
+		{[]string{"#+#", "#_", " # "}, ErrPrefabShape},
+		{[]string{}, ErrPrefabShape},
+		{[]string{"#+#", "#x#", " # "}, ErrPrefabLegend},
+	}
+	for _, c := range cases {
+		if _, err := NewPrefab(c.template, shrineLegend); err != c.err {
+			t.Errorf("NewPrefab(%q) gave error %v, expected %v", c.template, err, c.err)
+		}
+	}
+}
+
+func TestPrefabOrient(t *testing.T) {
+	p := MustPrefab([]string{
+		"##+",
+		"#_.",
+	}, shrineLegend)
+
+	cases := []struct {
+		turns    int
+		mirror   bool
+		expected []string
+	}{
+		{0, false, []string{"##+", "#_."}},
+		{1, false, []string{"##", "_#", ".+"}},
+		{2, false, []string{"._#", "+##"}},
+		{-1, false, []string{"+.", "#_", "##"}},
+		{0, true, []string{"+##", "._#"}},
+		{1, true, []string{"##", "#_", "+."}},
+	}
+	for _, c := range cases {
+		if lines := prefabLines(p.Orient(c.turns, c.mirror)); !reflect.DeepEqual(lines, c.expected) {
+			t.Errorf("Orient(%d, %v) gave %q, expected %q", c.turns, c.mirror, lines, c.expected)
+		}
+	}
+
+	if lines := prefabLines(p.Mirror().Mirror()); !reflect.DeepEqual(lines, prefabLines(p)) {
+		t.Errorf("Mirror twice gave %q", lines)
+	}
+}
+
+func TestPrefabStamp(t *testing.T) {
+	gen := MapGenInt(func(o Offset, tiletype int) *Tile {
+		tile := NewTile(o)
+		tile.Pass = tiletype != TileTypeWall
+		if tiletype == TileTypeDoor {
+			tile.Face = Glyph{'+', ColorWhite}
+		}
+		return tile
+	})
+	tiles := NewTileGrid(6, 6, Offset{}, func(o Offset) *Tile {
+		return NewTile(o)
+	})
+	p := MustPrefab([]string{
+		" ### ",
+		" #_+ ",
+		" ### ",
+	}, shrineLegend)
+
+	if _, err := p.Stamp(tiles, Offset{3, 0}, gen); err != ErrNoSite {
+		t.Errorf("Stamp outside map gave %v, expected ErrNoSite", err)
+	}
+	for _, tile := range tiles {
+		if !tile.Pass {
+			t.Fatalf("failed Stamp changed the map at %v", tile.Offset)
+		}
+	}
+
+	// the transparent corners may hang off the map
+	marks, err := p.Stamp(tiles, Offset{-1, 1}, gen)
+	if err != nil {
+		t.Fatalf("Stamp gave error %v", err)
+	}
+	if len(marks['#']) != 7 || len(marks['+']) != 1 || len(marks['_']) != 1 {
+		t.Errorf("Stamp gave wrong marks: %v", marks)
+	}
+	altar := marks['_'][0]
+	if altar.Offset != (Offset{1, 2}) || !altar.Pass {
+		t.Errorf("Stamp placed altar at %v", altar.Offset)
+	}
+	if door := marks['+'][0]; door.Face.Ch != '+' || door.Adjacent[Offset{-1, 0}] != altar {
+		t.Errorf("Stamp did not connect the door")
+	}
+	if NewRegions(tiles, Walking).Count() != 1 {
+		t.Errorf("Stamp disconnected the map")
+	}
+}
+
+func TestPrefabPlaceDungeon(t *testing.T) {
+	gen := MapGenInt(func(o Offset, tiletype int) *Tile {
+		tile := NewTile(o)
+		tile.Pass = tiletype != TileTypeWall
+		return tile
+	})
+	dungeon := Dungeon(30, 8, 12, gen)
+	vault := MustPrefab([]string{
+		"#####",
+		"#._.#",
+		"#...+",
+		"#####",
+	}, shrineLegend).RandOrient()
+
+	marks, err := vault.Place(dungeon, Clearing, gen)
+	if err != nil {
+		t.Fatalf("Place gave error %v", err)
+	}
+	if len(marks['_']) != 1 || NewRegions(dungeon, Walking).Count() != 1 {
+		t.Errorf("Place did not connect the vault to the Dungeon")
+	}
+
+	if _, err := vault.Place(dungeon, func([]*Tile) bool { return false }, gen); err != ErrNoSite {
+		t.Errorf("Place with no site gave %v, expected ErrNoSite", err)
+	}
+}
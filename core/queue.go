@@ -0,0 +1,71 @@
+package core
+
+import (
+	"container/heap"
+)
+
+// weightentry is an entry in a weightqueue, along with its weight at the time
+// it was queued. An entry is either a Tile, or the index of a Tile in a dense
+// structure such as a TileIndex, or a Tile at a time (with the time stored as
+// the index). Improving the weight of a queued entry does not update it.
+// Instead, the entry is simply queued again, and the stale entry is skipped
+// when it is eventually popped.
+type weightentry struct {
+	tile   *Tile
+	index  int
+	weight float64
+}
+
+// weightqueue implements heap.Interface, sorting weightentry by weight. It is
+// used by the weighted fields and searches which need Dijkstra's algorithm or
+// A*. Entries should be added and removed with push and pop rather than
+// heap.Push and heap.Pop, which box each entry in an interface{}, so that a
+// queue which is reused between searches does not allocate.
+type weightqueue []weightentry
+
+// Len returns the number of entries in the queue.
+func (q weightqueue) Len() int {
+	return len(q)
+}
+
+// Less returns true if the ith entry has a lower weight than the jth entry.
+func (q weightqueue) Less(i, j int) bool {
+	return q[i].weight < q[j].weight
+}
+
+// Swap switches the ith and jth entry in the queue.
+func (q weightqueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+// Push pushes a weightentry onto the queue, panicing if x is not a weightentry.
+func (q *weightqueue) Push(x interface{}) {
+	*q = append(*q, x.(weightentry))
+}
+
+// Pop removes and returns the last weightentry in the queue as an interface{}.
+func (q *weightqueue) Pop() interface{} {
+	n := len(*q) - 1
+	x := (*q)[n]
+	*q = (*q)[:n]
+	return x
+}
+
+// push adds an entry to the queue, as with heap.Push.
+func (q *weightqueue) push(e weightentry) {
+	*q = append(*q, e)
+	heap.Fix(q, len(*q)-1)
+}
+
+// pop removes and returns the entry with the lowest weight, as with heap.Pop.
+// The queue must not be empty.
+func (q *weightqueue) pop() weightentry {
+	n := len(*q) - 1
+	q.Swap(0, n)
+	e := (*q)[n]
+	*q = (*q)[:n]
+	if n > 0 {
+		heap.Fix(q, 0)
+	}
+	return e
+}
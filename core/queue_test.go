@@ -0,0 +1,23 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestWeightQueue(t *testing.T) {
+	dice := NewDice(newXorshift(1))
+	queue := &weightqueue{}
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 100; i++ {
+			queue.push(weightentry{index: i, weight: float64(dice.Intn(20))})
+		}
+		prev := -1.0
+		for n := 0; queue.Len() > 0; n++ {
+			entry := queue.pop()
+			if entry.weight < prev {
+				t.Fatalf("pop %d gave weight %v after %v", n, entry.weight, prev)
+			}
+			prev = entry.weight
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+// Regions labels the connected components of a map, so that reachability
+// between two Tiles can be checked without a search. Two Tiles are connected
+// if the Movement can walk between them through Adjacent Tiles or Stairs. Since
+// the labels are computed up front, a new Regions should be created whenever
+// the passability of the map changes, such as when a wall is dug out.
+type Regions struct {
+	labels map[*Tile]int
+	count  int
+}
+
+// NewRegions computes the connected components of the given Tiles for an
+// Entity using the Movement, with nil meaning Walking. Any Tile which the
+// Movement cannot enter is not part of any region.
+func NewRegions(tiles []*Tile, m Movement) *Regions {
+	r := &Regions{make(map[*Tile]int), 0}
+
+	for _, tile := range tiles {
+		if _, seen := r.labels[tile]; seen || !m.Passable(tile) {
+			continue
+		}
+
+		// flood fill the component containing tile with the next label
+		label := r.count
+		r.count++
+		r.labels[tile] = label
+		frontier := []*Tile{tile}
+		for len(frontier) > 0 {
+			curr := frontier[len(frontier)-1]
+			frontier = frontier[:len(frontier)-1]
+
+			visit := func(adj *Tile) {
+				if _, seen := r.labels[adj]; !seen && m.Passable(adj) {
+					r.labels[adj] = label
+					frontier = append(frontier, adj)
+				}
+			}
+			for _, adj := range curr.Adjacent {
+				visit(adj)
+			}
+			for _, adj := range curr.Stairs {
+				visit(adj)
+			}
+		}
+	}
+
+	return r
+}
+
+// Count returns the number of regions.
+func (r *Regions) Count() int {
+	return r.count
+}
+
+// Label returns the region containing the given Tile. If the Tile is not part
+// of any region, ok will be false.
+func (r *Regions) Label(t *Tile) (label int, ok bool) {
+	label, ok = r.labels[t]
+	return label, ok
+}
+
+// Connected returns true if a path exists between the two Tiles. The origin
+// need not be passable, since an Entity may already occupy it, but it must
+// border the region containing the goal.
+func (r *Regions) Connected(origin, goal *Tile) bool {
+	label, ok := r.labels[goal]
+	if !ok {
+		return false
+	}
+	if other, ok := r.labels[origin]; ok {
+		return other == label
+	}
+	for _, adj := range origin.Adjacent {
+		if other, ok := r.labels[adj]; ok && other == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestRegions(t *testing.T) {
+	var tiles []*Tile
+	marks := make(map[byte]*Tile)
+	StrGrid{
+		"#########",
+		"#a.#c..~#",
+		"#..#..#~#",
+		"#.b#.d#f#",
+		"#########",
+	}.Convert(func(t *Tile, c byte) {
+		tiles = append(tiles, t)
+		switch c {
+		case '#':
+			t.Pass = false
+		case '~':
+			t.Pass = false
+			t.Face.Ch = '~'
+		case '.':
+		default:
+			marks[c] = t
+		}
+	})
+
+	regions := NewRegions(tiles, nil)
+	if regions.Count() != 3 {
+		t.Errorf("NewRegions found %d regions, expected 3", regions.Count())
+	}
+
+	cases := []struct {
+		a, b     byte
+		expected bool
+	}{
+		{'a', 'b', true},
+		{'c', 'd', true},
+		{'a', 'c', false},
+		{'d', 'f', false},
+	}
+	for _, c := range cases {
+		if actual := regions.Connected(marks[c.a], marks[c.b]); actual != c.expected {
+			t.Errorf("Connected(%c, %c) = %v, expected %v", c.a, c.b, actual, c.expected)
+		}
+	}
+
+	// a swimmer can reach f through the water, joining it with c and d
+	swimming := NewRegions(tiles, Profile(map[rune]float64{'~': 3}, Walking))
+	if swimming.Count() != 2 || !swimming.Connected(marks['c'], marks['f']) {
+		t.Errorf("NewRegions did not join regions through water")
+	}
+
+	// an occupied origin which is impassable still connects through neighbors
+	marks['a'].Pass = false
+	if !regions.Connected(marks['a'], marks['b']) {
+		t.Errorf("Connected failed from impassable origin")
+	}
+	if _, ok := regions.Label(tiles[0]); ok {
+		t.Errorf("Label gave region for wall")
+	}
+}
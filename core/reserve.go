@@ -0,0 +1,210 @@
+package core
+
+// spacetime is a Tile at a particular time.
+type spacetime struct {
+	tile *Tile
+	time int
+}
+
+// rest records that an Entity stays on a Tile from some time onwards.
+type rest struct {
+	entity Entity
+	from   int
+}
+
+// claim records the reservations made by a single Entity, so that they can be
+// released without scanning the whole ReservationTable.
+type claim struct {
+	cells []spacetime
+	rest  *Tile
+}
+
+// ReservationTable tracks which Tiles several Entities plan to occupy at each
+// time, so that the Entities can route cooperatively, such as a tribe passing
+// through a chokepoint. Each Entity plans a path with Path, which avoids the
+// reservations of the other Entities, and then claims that path with Reserve.
+// Times are arbitrary integers, such as the number of turns elapsed, with each
+// step of a path taking one unit of time. Since Entities are used as keys, they
+// must be comparable, such as pointers.
+type ReservationTable struct {
+	cells  map[spacetime]Entity
+	rests  map[*Tile]rest
+	claims map[Entity]claim
+}
+
+// NewReservationTable creates an empty ReservationTable.
+func NewReservationTable() *ReservationTable {
+	return &ReservationTable{make(map[spacetime]Entity), make(map[*Tile]rest), make(map[Entity]claim)}
+}
+
+// Reserve claims a path for an Entity, replacing any previous reservations
+// made by that Entity. The origin is reserved at the start time, and each step
+// of the path at each subsequent time. The Entity is assumed to remain at the
+// end of the path indefinitely, so the final Tile stays reserved until the
+// Entity reserves a new path or is released.
+func (r *ReservationTable) Reserve(e Entity, origin *Tile, path []*Tile, start int) {
+	r.Release(e)
+
+	c := claim{cells: make([]spacetime, 0, len(path)+1), rest: origin}
+	c.cells = append(c.cells, spacetime{origin, start})
+	for i, tile := range path {
+		c.cells = append(c.cells, spacetime{tile, start + i + 1})
+		c.rest = tile
+	}
+	for _, cell := range c.cells {
+		r.cells[cell] = e
+	}
+	r.rests[c.rest] = rest{e, start + len(path)}
+	r.claims[e] = c
+}
+
+// Release removes all reservations made by an Entity. Reservations which have
+// since been overwritten by another Entity are left alone.
+func (r *ReservationTable) Release(e Entity) {
+	c, ok := r.claims[e]
+	if !ok {
+		return
+	}
+	for _, cell := range c.cells {
+		if r.cells[cell] == e {
+			delete(r.cells, cell)
+		}
+	}
+	if rest, ok := r.rests[c.rest]; ok && rest.entity == e {
+		delete(r.rests, c.rest)
+	}
+	delete(r.claims, e)
+}
+
+// Prune removes reservations for times before the given time, which can no
+// longer affect any new path.
+func (r *ReservationTable) Prune(before int) {
+	for e, c := range r.claims {
+		kept := c.cells[:0]
+		for _, cell := range c.cells {
+			if cell.time < before {
+				if r.cells[cell] == e {
+					delete(r.cells, cell)
+				}
+			} else {
+				kept = append(kept, cell)
+			}
+		}
+		c.cells = kept
+		r.claims[e] = c
+	}
+}
+
+// Reserved returns the Entity which has reserved the Tile at the given time,
+// or nil if the Tile is free.
+func (r *ReservationTable) Reserved(t *Tile, time int) Entity {
+	if owner, ok := r.cells[spacetime{t, time}]; ok {
+		return owner
+	}
+	if rest, ok := r.rests[t]; ok && time >= rest.from {
+		return rest.entity
+	}
+	return nil
+}
+
+// free returns true if the Tile is not reserved by an Entity other than e at
+// the given time.
+func (r *ReservationTable) free(e Entity, t *Tile, time int) bool {
+	owner := r.Reserved(t, time)
+	return owner == nil || owner == e
+}
+
+// crosses returns true if moving from a to b starting at the given time would
+// swap places with another Entity moving from b to a, which would otherwise
+// let two Entities pass through each other in a corridor.
+func (r *ReservationTable) crosses(e Entity, a, b *Tile, time int) bool {
+	owner, ok := r.cells[spacetime{b, time}]
+	return ok && owner != e && r.cells[spacetime{a, time + 1}] == owner
+}
+
+// settles returns true if the Entity can remain on the Tile from the given time
+// onwards without running into the reservations of any other Entity.
+func (r *ReservationTable) settles(e Entity, t *Tile, time, horizon int) bool {
+	if rest, ok := r.rests[t]; ok && rest.entity != e {
+		return false
+	}
+	for ; time <= horizon; time++ {
+		if !r.free(e, t, time) {
+			return false
+		}
+	}
+	return true
+}
+
+// Path computes a minimum cost path for an Entity between two Tiles starting at
+// the given time, which avoids the reservations of every other Entity. Unlike
+// GraphSearch, the path may wait in place, in which case the same Tile appears
+// consecutively in the path. Reservations are only respected for the given
+// window of time after the start, beyond which the path is planned as if no
+// other Entity were present. Larger windows allow more cooperation, at the
+// cost of a more expensive search. If no path exists, nil is returned.
+func (r *ReservationTable) Path(e Entity, origin, goal *Tile, start, window int) []*Tile {
+	horizon := start + window
+
+	// setup bookkeeping for a space-time A* search, in which times past the
+	// horizon are all treated as the horizon itself.
+	begin := spacetime{origin, start}
+	gcost := map[spacetime]float64{begin: 0}
+	prev := make(map[spacetime]spacetime)
+	closed := make(map[spacetime]struct{})
+	frontier := &weightqueue{{tile: origin, index: start, weight: euclidean(origin, goal)}}
+
+	for frontier.Len() > 0 {
+		// get the next state to explore, skip if we've already closed it
+		entry := frontier.pop()
+		curr := spacetime{entry.tile, entry.index}
+		if _, seen := closed[curr]; seen {
+			continue
+		}
+		closed[curr] = struct{}{}
+
+		// if we find the goal and can stay there, we've found the best path
+		if curr.tile == goal && r.settles(e, goal, curr.time, horizon) {
+			var path []*Tile
+			for ; curr != begin; curr = prev[curr] {
+				path = append(path, curr.tile)
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+
+		// consider each neighbor, as well as waiting if still within the window
+		next := curr.time + 1
+		if next > horizon {
+			next = horizon
+		}
+		expand := func(adj *Tile, cost float64) {
+			state := spacetime{adj, next}
+			if _, seen := closed[state]; seen || !adj.Pass {
+				return
+			}
+			if curr.time < horizon {
+				if !r.free(e, adj, next) || r.crosses(e, curr.tile, adj, curr.time) {
+					return
+				}
+			}
+			cost += gcost[curr]
+			if prevcost, ok := gcost[state]; !ok || cost < prevcost {
+				gcost[state] = cost
+				prev[state] = curr
+				frontier.push(weightentry{tile: state.tile, index: state.time, weight: cost + euclidean(adj, goal)})
+			}
+		}
+		for _, adj := range curr.tile.Adjacent {
+			expand(adj, euclidean(curr.tile, adj))
+		}
+		if curr.time < horizon {
+			expand(curr.tile, 1)
+		}
+	}
+
+	// if we exhaust the frontier, and didn't find the goal, there is no path
+	return nil
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"testing"
+)
+
+// ReservationCase converts a StrGrid into a corridor with two Entities, 'a' and
+// 'b', each of which must reach the starting position of the other.
+func ReservationCase(g StrGrid) (a, b *Tile) {
+	g.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#':
+			t.Pass = false
+		case 'a':
+			a = t
+		case 'b':
+			b = t
+		}
+	})
+	return a, b
+}
+
+// positionAt returns the position of an Entity following a path at time i.
+func positionAt(origin *Tile, path []*Tile, i int) *Tile {
+	if i == 0 {
+		return origin
+	}
+	if i > len(path) {
+		return path[len(path)-1]
+	}
+	return path[i-1]
+}
+
+func TestReservationTablePath(t *testing.T) {
+	cases := []StrGrid{
+		{
+			"#######",
+			"#a...b#",
+			"###.###",
+			"#######",
+		}, {
+			"#########",
+			"#a.....b#",
+			"#####.###",
+			"#########",
+		}, {
+			"##########",
+			"#a.......#",
+			"#.######.#",
+			"#........#",
+			"#######.b#",
+			"##########",
+		},
+	}
+	for i, c := range cases {
+		a, b := ReservationCase(c)
+		ea, eb := &posRecorder{a}, &posRecorder{b}
+
+		table := NewReservationTable()
+		patha := table.Path(ea, a, b, 0, 20)
+		table.Reserve(ea, a, patha, 0)
+		pathb := table.Path(eb, b, a, 0, 20)
+		table.Reserve(eb, b, pathb, 0)
+
+		if patha == nil || pathb == nil {
+			t.Errorf("ReservationTable.Path found no path for case %d", i)
+			continue
+		}
+		if patha[len(patha)-1] != b || pathb[len(pathb)-1] != a {
+			t.Errorf("ReservationTable.Path did not reach goal for case %d", i)
+			continue
+		}
+
+		// step through time, checking for collisions or swaps
+		for j := 0; j < len(patha)+len(pathb); j++ {
+			currA, currB := positionAt(a, patha, j), positionAt(b, pathb, j)
+			nextA, nextB := positionAt(a, patha, j+1), positionAt(b, pathb, j+1)
+			if nextA != currA && !PathValid([]*Tile{currA, nextA}) {
+				t.Errorf("ReservationTable.Path gave invalid step for case %d", i)
+			}
+			if currA == currB {
+				t.Errorf("ReservationTable.Path collided at time %d for case %d", j, i)
+			}
+			if currA == nextB && currB == nextA {
+				t.Errorf("ReservationTable.Path swapped at time %d for case %d", j, i)
+			}
+		}
+	}
+}
+
+func TestReservationTableBlocked(t *testing.T) {
+	a, b := ReservationCase(StrGrid{
+		"######",
+		"#a..b#",
+		"######",
+	})
+	ea, eb := &posRecorder{a}, &posRecorder{b}
+
+	// with no room to pass, b must give up once a has claimed the corridor
+	table := NewReservationTable()
+	table.Reserve(ea, a, table.Path(ea, a, b, 0, 10), 0)
+	if path := table.Path(eb, b, a, 0, 10); path != nil {
+		t.Errorf("ReservationTable.Path passed through reserved corridor")
+	}
+
+	// once a is released, b has the corridor to itself
+	table.Release(ea)
+	if path := table.Path(eb, b, a, 0, 10); len(path) != 3 {
+		t.Errorf("ReservationTable.Path gave %d steps after Release", len(path))
+	}
+}
+
+func TestReservationTableRelease(t *testing.T) {
+	a, b := ReservationCase(StrGrid{
+		"######",
+		"#a..b#",
+		"######",
+	})
+	ea, eb := &posRecorder{a}, &posRecorder{b}
+	mid := a.Adjacent[Offset{1, 0}]
+
+	// b overwrites the reservation a made on mid at time 1
+	table := NewReservationTable()
+	table.Reserve(ea, a, []*Tile{mid}, 0)
+	table.Reserve(eb, b, []*Tile{b.Adjacent[Offset{-1, 0}], mid}, -1)
+	table.Release(ea)
+	if table.Reserved(a, 0) != nil || table.Reserved(mid, 5) != eb {
+		t.Errorf("ReservationTable.Release removed the wrong reservations")
+	}
+	if owner := table.Reserved(mid, 1); owner != eb {
+		t.Errorf("ReservationTable.Release removed a reservation made by another Entity")
+	}
+
+	// pruning keeps the claim of b consistent with the table
+	table.Prune(1)
+	if table.Reserved(b, -1) != nil || table.Reserved(mid, 1) != eb {
+		t.Errorf("ReservationTable.Prune removed the wrong reservations")
+	}
+	table.Release(eb)
+	if len(table.cells) != 0 || len(table.rests) != 0 || len(table.claims) != 0 {
+		t.Errorf("ReservationTable.Release left reservations behind")
+	}
+}
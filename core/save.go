@@ -0,0 +1,299 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// MapFormatVersion is the version of the format written by SaveMap. LoadMap
+// reads any version up to and including this one.
+const MapFormatVersion = 1
+
+// mapMagic starts every saved map, so that other files are rejected quickly.
+const mapMagic = "STONESMAP"
+
+// Flags stored with each Tile in a saved map.
+const (
+	mapFlagPass = 1 << iota
+	mapFlagLite
+	mapFlagOccupant
+)
+
+// OccupantCodec lets SaveMap and LoadMap handle the Occupant of each Tile.
+// Since an Occupant may be any Entity, the map format cannot encode it by
+// itself, so Encode gives the bytes for an Occupant, and Decode recreates an
+// Occupant from those bytes. Decode is given the Tile the Occupant stands on,
+// after every Tile of the map has been loaded and linked, so that the Entity
+// can record its position. If Encode returns nil bytes for an Occupant, that
+// Occupant is not saved.
+type OccupantCodec struct {
+	Encode func(e Entity) ([]byte, error)
+	Decode func(data []byte, t *Tile) (Entity, error)
+}
+
+// mapEncoder writes the parts of a saved map, remembering the first error.
+type mapEncoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+// writeUint writes an unsigned varint.
+func (e *mapEncoder) writeUint(v uint64) {
+	if e.err == nil {
+		_, e.err = e.w.Write(e.buf[:binary.PutUvarint(e.buf[:], v)])
+	}
+}
+
+// writeInt writes a signed varint.
+func (e *mapEncoder) writeInt(v int64) {
+	if e.err == nil {
+		_, e.err = e.w.Write(e.buf[:binary.PutVarint(e.buf[:], v)])
+	}
+}
+
+// writeBytes writes a length prefixed slice of bytes.
+func (e *mapEncoder) writeBytes(b []byte) {
+	e.writeUint(uint64(len(b)))
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+// mapDecoder reads the parts of a saved map, remembering the first error.
+type mapDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+// readUint reads an unsigned varint.
+func (d *mapDecoder) readUint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+// readInt reads a signed varint.
+func (d *mapDecoder) readInt() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var v int64
+	v, d.err = binary.ReadVarint(d.r)
+	return v
+}
+
+// readBytes reads a length prefixed slice of bytes.
+func (d *mapDecoder) readBytes() []byte {
+	n := d.readUint()
+	if d.err == nil && n > 1<<62 {
+		d.err = ErrMapFormat
+	}
+	if d.err != nil {
+		return nil
+	}
+	// the length is untrusted, so only allocate for the data actually read
+	var b []byte
+	if b, d.err = io.ReadAll(io.LimitReader(d.r, int64(n))); d.err == nil && uint64(len(b)) != n {
+		d.err = ErrMapFormat
+	}
+	return b
+}
+
+// readIndex reads the index of a Tile, which must be less than count.
+func (d *mapDecoder) readIndex(count int) int {
+	i := d.readUint()
+	if d.err == nil && i >= uint64(count) {
+		d.err = ErrMapFormat
+	}
+	return int(i)
+}
+
+// sortedSteps gives the keys of an Adjacent map in a fixed order, so that
+// saving the same map always gives the same bytes, and so that a random choice
+// of neighbor is the same given the same Dice.
+func sortedSteps(adjacent map[Offset]*Tile) []Offset {
+	steps := make([]Offset, 0, len(adjacent))
+	for step := range adjacent {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		if steps[i].X != steps[j].X {
+			return steps[i].X < steps[j].X
+		}
+		return steps[i].Y < steps[j].Y
+	})
+	return steps
+}
+
+// SaveMap writes a map to w in a compact binary format, which LoadMap reads
+// back. The Face, Pass, Lite, Offset and Level of each Tile are saved, along
+// with every Adjacent and Stairs link, so maps with links which do not follow a
+// grid, such as mazes and dungeons, are saved exactly. Links to Tiles which are
+// not in the given slice are dropped. Occupants are saved using the codec, and
+// if the codec is nil, or has no Encode, Occupants are not saved.
+func SaveMap(w io.Writer, tiles []*Tile, codec *OccupantCodec) error {
+	e := &mapEncoder{w: bufio.NewWriter(w)}
+	index := make(map[*Tile]int, len(tiles))
+	for i, tile := range tiles {
+		index[tile] = i
+	}
+
+	// encode the Occupants first, so that the flags are known
+	occupants := make([][]byte, len(tiles))
+	if codec != nil && codec.Encode != nil {
+		for i, tile := range tiles {
+			if tile.Occupant == nil {
+				continue
+			}
+			data, err := codec.Encode(tile.Occupant)
+			if err != nil {
+				return err
+			}
+			occupants[i] = data
+		}
+	}
+
+	_, e.err = e.w.WriteString(mapMagic)
+	e.writeUint(MapFormatVersion)
+	e.writeUint(uint64(len(tiles)))
+	for i, tile := range tiles {
+		var flags uint64
+		if tile.Pass {
+			flags |= mapFlagPass
+		}
+		if tile.Lite {
+			flags |= mapFlagLite
+		}
+		if occupants[i] != nil {
+			flags |= mapFlagOccupant
+		}
+		e.writeUint(flags)
+		e.writeInt(int64(tile.Face.Ch))
+		e.writeUint(uint64(tile.Face.Fg))
+		e.writeInt(int64(tile.Offset.X))
+		e.writeInt(int64(tile.Offset.Y))
+		e.writeInt(int64(tile.Level))
+	}
+
+	for _, tile := range tiles {
+		var steps []Offset
+		for _, step := range sortedSteps(tile.Adjacent) {
+			if _, ok := index[tile.Adjacent[step]]; ok {
+				steps = append(steps, step)
+			}
+		}
+		e.writeUint(uint64(len(steps)))
+		for _, step := range steps {
+			e.writeInt(int64(step.X))
+			e.writeInt(int64(step.Y))
+			e.writeUint(uint64(index[tile.Adjacent[step]]))
+		}
+
+		var deltas []int
+		for delta, dest := range tile.Stairs {
+			if _, ok := index[dest]; ok {
+				deltas = append(deltas, delta)
+			}
+		}
+		sort.Ints(deltas)
+		e.writeUint(uint64(len(deltas)))
+		for _, delta := range deltas {
+			e.writeInt(int64(delta))
+			e.writeUint(uint64(index[tile.Stairs[delta]]))
+		}
+	}
+
+	for _, data := range occupants {
+		if data != nil {
+			e.writeBytes(data)
+		}
+	}
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// LoadMap reads a map written by SaveMap, returning the Tiles in the order they
+// were saved. Occupants are recreated using the codec, and if the codec is nil,
+// or has no Decode, any saved Occupants are skipped. If the data was not
+// written by SaveMap, ErrMapFormat is returned, and if it was written by a
+// newer version of SaveMap, ErrMapVersion is returned.
+func LoadMap(r io.Reader, codec *OccupantCodec) ([]*Tile, error) {
+	d := &mapDecoder{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(mapMagic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != mapMagic {
+		return nil, ErrMapFormat
+	}
+	if version := d.readUint(); d.err == nil && (version == 0 || version > MapFormatVersion) {
+		return nil, ErrMapVersion
+	}
+
+	// the count is untrusted, so grow the slice as Tiles are actually read
+	count := d.readUint()
+	var tiles []*Tile
+	var flags []uint64
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		tile := NewTile(Offset{})
+		flags = append(flags, d.readUint())
+		tile.Face.Ch = rune(d.readInt())
+		tile.Face.Fg = Color(d.readUint())
+		tile.Offset.X = int(d.readInt())
+		tile.Offset.Y = int(d.readInt())
+		tile.Level = int(d.readInt())
+		tile.Pass = flags[i]&mapFlagPass != 0
+		tile.Lite = flags[i]&mapFlagLite != 0
+		tiles = append(tiles, tile)
+	}
+
+	for _, tile := range tiles {
+		links := d.readUint()
+		for j := uint64(0); j < links && d.err == nil; j++ {
+			step := Offset{int(d.readInt()), int(d.readInt())}
+			if i := d.readIndex(len(tiles)); d.err == nil {
+				tile.Adjacent[step] = tiles[i]
+			}
+		}
+
+		stairs := d.readUint()
+		for j := uint64(0); j < stairs && d.err == nil; j++ {
+			delta := int(d.readInt())
+			if i := d.readIndex(len(tiles)); d.err == nil {
+				if tile.Stairs == nil {
+					tile.Stairs = make(map[int]*Tile)
+				}
+				tile.Stairs[delta] = tiles[i]
+			}
+		}
+	}
+
+	for i, tile := range tiles {
+		if flags[i]&mapFlagOccupant == 0 {
+			continue
+		}
+		data := d.readBytes()
+		if d.err != nil || codec == nil || codec.Decode == nil {
+			continue
+		}
+		occupant, err := codec.Decode(data, tile)
+		if err != nil {
+			return nil, err
+		}
+		tile.Occupant = occupant
+	}
+
+	if d.err == io.EOF || d.err == io.ErrUnexpectedEOF {
+		return nil, ErrMapFormat
+	} else if d.err != nil {
+		return nil, d.err
+	}
+	return tiles, nil
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// namedEntity is an Entity which is saved by its name.
+type namedEntity struct {
+	name string
+	posRecorder
+}
+
+// namedCodec saves namedEntity by name, and records the position of each
+// loaded namedEntity.
+var namedCodec = &OccupantCodec{
+	Encode: func(e Entity) ([]byte, error) {
+		if e, ok := e.(*namedEntity); ok {
+			return []byte(e.name), nil
+		}
+		return nil, nil
+	},
+	Decode: func(data []byte, t *Tile) (Entity, error) {
+		e := &namedEntity{name: string(data)}
+		e.pos = t
+		return e, nil
+	},
+}
+
+// saveTestMap creates a woven maze with a lower Level reached by stairs, and a
+// few occupants.
+func saveTestMap() []*Tile {
+	maze := PerfectMaze(40, .5, .3)
+	below := NewTileGrid(3, 3, Offset{}, func(o Offset) *Tile {
+		t := NewTile(o)
+		t.Level = -1
+		t.Face = Glyph{'~', ColorLightBlue}
+		t.Lite = false
+		return t
+	})
+	LinkStairs(maze[0], below[4])
+
+	maze[1].Occupant = &namedEntity{name: "hero"}
+	maze[2].Occupant = &posRecorder{}
+	below[0].Occupant = &namedEntity{name: "rat"}
+	return append(maze, below...)
+}
+
+func TestSaveMapRoundTrip(t *testing.T) {
+	tiles := saveTestMap()
+	var saved bytes.Buffer
+	if err := SaveMap(&saved, tiles, namedCodec); err != nil {
+		t.Fatalf("SaveMap gave error %v", err)
+	}
+
+	loaded, err := LoadMap(bytes.NewReader(saved.Bytes()), namedCodec)
+	if err != nil {
+		t.Fatalf("LoadMap gave error %v", err)
+	}
+	if len(loaded) != len(tiles) {
+		t.Fatalf("LoadMap gave %d Tiles, expected %d", len(loaded), len(tiles))
+	}
+
+	index := make(map[*Tile]int)
+	for i, tile := range loaded {
+		index[tile] = i
+	}
+	for i, tile := range tiles {
+		got := loaded[i]
+		if got.Face != tile.Face || got.Pass != tile.Pass || got.Lite != tile.Lite || got.Offset != tile.Offset || got.Level != tile.Level {
+			t.Errorf("LoadMap gave %+v, expected %+v", *got, *tile)
+		}
+		if len(got.Adjacent) != len(tile.Adjacent) || len(got.Stairs) != len(tile.Stairs) {
+			t.Errorf("LoadMap lost links at %v", tile.Offset)
+		}
+		for step, adj := range tile.Adjacent {
+			if j, ok := index[got.Adjacent[step]]; !ok || tiles[j] != adj {
+				t.Errorf("LoadMap gave wrong link at %v step %v", tile.Offset, step)
+			}
+		}
+		for delta, dest := range tile.Stairs {
+			if j, ok := index[got.Stairs[delta]]; !ok || tiles[j] != dest {
+				t.Errorf("LoadMap gave wrong stairs at %v", tile.Offset)
+			}
+		}
+	}
+
+	// only the named occupants are saved, and they know where they stand
+	for i, tile := range loaded {
+		named, ok := tiles[i].Occupant.(*namedEntity)
+		if !ok {
+			if tile.Occupant != nil {
+				t.Errorf("LoadMap gave unsaved Occupant at %v", tile.Offset)
+			}
+			continue
+		}
+		if e, ok := tile.Occupant.(*namedEntity); !ok || e.name != named.name || e.pos != tile {
+			t.Errorf("LoadMap gave Occupant %v, expected %s", tile.Occupant, named.name)
+		}
+	}
+
+	// saving the loaded map should give exactly the same bytes
+	var resaved bytes.Buffer
+	if err := SaveMap(&resaved, loaded, namedCodec); err != nil {
+		t.Fatalf("SaveMap gave error %v", err)
+	}
+	if !bytes.Equal(saved.Bytes(), resaved.Bytes()) {
+		t.Errorf("SaveMap of loaded map gave different bytes")
+	}
+}
+
+func TestSaveMapNoCodec(t *testing.T) {
+	tiles := saveTestMap()
+	var withCodec, without bytes.Buffer
+	SaveMap(&withCodec, tiles, namedCodec)
+	SaveMap(&without, tiles, nil)
+	if without.Len() >= withCodec.Len() {
+		t.Errorf("SaveMap without codec saved occupants")
+	}
+
+	loaded, err := LoadMap(&withCodec, nil)
+	if err != nil {
+		t.Fatalf("LoadMap without codec gave error %v", err)
+	}
+	for _, tile := range loaded {
+		if tile.Occupant != nil {
+			t.Errorf("LoadMap without codec gave Occupant")
+		}
+	}
+}
+
+func TestLoadMapInvalid(t *testing.T) {
+	var saved bytes.Buffer
+	if err := SaveMap(&saved, saveTestMap(), namedCodec); err != nil {
+		t.Fatalf("SaveMap gave error %v", err)
+	}
+	data := saved.Bytes()
+
+	newer := append([]byte(mapMagic), MapFormatVersion+1)
+	newer = append(newer, data[len(mapMagic)+1:]...)
+
+	cases := []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte("not a map"), ErrMapFormat},
+		{[]byte{}, ErrMapFormat},
+		{newer, ErrMapVersion},
+		{data[:len(data)/2], ErrMapFormat},
+		{data[:len(data)-1], ErrMapFormat},
+	}
+	for i, c := range cases {
+		if _, err := LoadMap(bytes.NewReader(c.data), namedCodec); err != c.err {
+			t.Errorf("case %d: LoadMap gave error %v, expected %v", i, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package core
+
+// ScentMap tracks the scent deposited on Tiles by moving Entities. On each
+// Tick, the scent decays and spreads slightly to neighboring passable Tiles.
+// A ScentMap is also a Field which follows the scent gradient uphill, so it
+// can be used to track prey.
+type ScentMap struct {
+	scent map[*Tile]float64
+	order []*Tile // scented Tiles in the order they were first scented
+
+	Decay     float64
+	Spread    float64
+	Threshold float64
+}
+
+// NewScentMap creates an empty ScentMap with default decay parameters. Each
+// Tick, 10% of the scent on a Tile decays, and 10% spreads to its neighbors.
+// Once the scent on a Tile drops below .01, it is removed entirely.
+func NewScentMap() *ScentMap {
+	return &ScentMap{make(map[*Tile]float64), nil, .1, .1, .01}
+}
+
+// Deposit adds scent to a Tile.
+func (m *ScentMap) Deposit(t *Tile, amount float64) {
+	if _, ok := m.scent[t]; !ok {
+		m.order = append(m.order, t)
+	}
+	m.scent[t] += amount
+}
+
+// Scent returns the amount of scent on a Tile.
+func (m *ScentMap) Scent(t *Tile) float64 {
+	return m.scent[t]
+}
+
+// Tick decays the scent on each Tile and spreads some of it to neighbors. The
+// Tiles are visited in a fixed order, so that the scent is summed in the same
+// order, and the same trails always give exactly the same scent.
+func (m *ScentMap) Tick() {
+	next := make(map[*Tile]float64, len(m.scent))
+	var order []*Tile
+	add := func(t *Tile, amount float64) {
+		if _, ok := next[t]; !ok {
+			order = append(order, t)
+		}
+		next[t] += amount
+	}
+
+	for _, tile := range m.order {
+		amount := m.scent[tile] * (1 - m.Decay)
+
+		// spread part of the scent evenly to passable neighbors
+		var neighbors []*Tile
+		for _, step := range sortedSteps(tile.Adjacent) {
+			if adj := tile.Adjacent[step]; adj.Pass {
+				neighbors = append(neighbors, adj)
+			}
+		}
+		if len(neighbors) > 0 {
+			share := amount * m.Spread / float64(len(neighbors))
+			for _, adj := range neighbors {
+				add(adj, share)
+			}
+			amount -= share * float64(len(neighbors))
+		}
+
+		add(tile, amount)
+	}
+
+	// remove any scent which has become too faint to matter
+	m.order = order[:0]
+	for _, tile := range order {
+		if next[tile] < m.Threshold {
+			delete(next, tile)
+		} else {
+			m.order = append(m.order, tile)
+		}
+	}
+
+	m.scent = next
+}
+
+// Handle implements Entity for ScentMap, so that a ScentMap can be scheduled
+// on a DeltaClock. Each Tick Event decays the scent.
+func (m *ScentMap) Handle(v Event) {
+	if _, ok := v.(*Tick); ok {
+		m.Tick()
+	}
+}
+
+// Weight implements WeightedField for ScentMap. Since stronger scent should be
+// more attractive, the weight is the negated scent.
+func (m *ScentMap) Weight(t *Tile) float64 {
+	return -m.scent[t]
+}
+
+// Follow returns an Offset from the given Tile which will lead to the
+// neighboring passable Tile with the strongest scent. If no neighbor has a
+// stronger scent than the given Tile, the zero Offset is returned.
+func (m *ScentMap) Follow(t *Tile) Offset {
+	maxScent, maxOffset := m.scent[t], Offset{}
+
+	for _, offset := range sortedSteps(t.Adjacent) {
+		adj := t.Adjacent[offset]
+		if scent := m.scent[adj]; adj.Pass && scent > maxScent {
+			maxScent = scent
+			maxOffset = offset
+		}
+	}
+
+	return maxOffset
+}
+
+// Overlay recolors a Glyph based on the scent of the given Tile, and can be
+// used as the Overlay of a CameraWidget to visualize scent for debugging.
+func (m *ScentMap) Overlay(t *Tile, g Glyph) Glyph {
+	switch scent := m.scent[t]; {
+	case scent >= 1:
+		g.Fg = ColorLightMagenta
+	case scent > 0:
+		g.Fg = ColorMagenta
+	}
+	return g
+}
+
+// ScentTrail is a Component which deposits scent on each Tile that its Entity
+// moves to. The Strength is the amount of scent deposited per move.
+type ScentTrail struct {
+	Map      *ScentMap
+	Strength float64
+}
+
+// Process implements Component for ScentTrail.
+func (c *ScentTrail) Process(v Event) {
+	if v, ok := v.(*UpdatePos); ok {
+		c.Map.Deposit(v.Pos, c.Strength)
+	}
+}
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScentMapTick(t *testing.T) {
+	var a, b *Tile
+	StrGrid{
+		"#####",
+		"#a.b#",
+		"#####",
+	}.Convert(func(t *Tile, c byte) {
+		switch c {
+		case '#':
+			t.Pass = false
+		case 'a':
+			a = t
+		case 'b':
+			b = t
+		}
+	})
+
+	m := NewScentMap()
+	m.Deposit(a, 10)
+	m.Handle(&Tick{})
+
+	mid := a.Adjacent[Offset{1, 0}]
+	if expected := 10 * .9 * .9; math.Abs(m.Scent(a)-expected) > 1e-9 {
+		t.Errorf("ScentMap decayed to %f, expected %f", m.Scent(a), expected)
+	}
+	if expected := 10 * .9 * .1; math.Abs(m.Scent(mid)-expected) > 1e-9 {
+		t.Errorf("ScentMap spread %f, expected %f", m.Scent(mid), expected)
+	}
+	if m.Scent(b) != 0 {
+		t.Errorf("ScentMap spread too far")
+	}
+
+	for i := 0; i < 100; i++ {
+		m.Tick()
+	}
+	if m.Scent(a) != 0 || m.Scent(mid) != 0 || m.Scent(b) != 0 {
+		t.Errorf("ScentMap did not decay below threshold")
+	}
+}
+
+func TestScentMapFollow(t *testing.T) {
+	var tiles []*Tile
+	StrGrid{
+		"#######",
+		"#.....#",
+		"#.....#",
+		"#.....#",
+		"#######",
+	}.Convert(func(t *Tile, c byte) {
+		if c == '#' {
+			t.Pass = false
+		} else {
+			tiles = append(tiles, t)
+		}
+	})
+
+	m := NewScentMap()
+	trail := &ScentTrail{m, 1}
+	var pos *Tile
+	for _, tile := range tiles {
+		if tile.Offset.Y == 2 {
+			trail.Process(&UpdatePos{tile})
+			m.Tick()
+			pos = tile
+		}
+	}
+
+	// starting at the cold end of the trail, we should arrive at the hot end
+	curr := tiles[1]
+	for step := m.Follow(curr); step != (Offset{}); step = m.Follow(curr) {
+		curr = curr.Adjacent[step]
+	}
+	if curr != pos {
+		t.Errorf("ScentMap Follow ended at %v, expected %v", curr.Offset, pos.Offset)
+	}
+}
+
+func TestScentMapDeterministic(t *testing.T) {
+	tiles := NewTileGrid(20, 20, Offset{}, NewTile)
+	var maps [2]*ScentMap
+	for i := range maps {
+		m := NewScentMap()
+		for j := 0; j < 200; j++ {
+			m.Deposit(tiles[(j*37)%len(tiles)], 1)
+			m.Tick()
+		}
+		maps[i] = m
+	}
+	for _, tile := range tiles {
+		if a, b := maps[0].Scent(tile), maps[1].Scent(tile); a != b {
+			t.Fatalf("ScentMap gave %v and %v for the same trail at %v", a, b, tile.Offset)
+		}
+	}
+}
+
+// stepper is a Component which moves its Entity east on each Tick.
+type stepper struct {
+	pos *Tile
+}
+
+// Process implements Component for stepper.
+func (c *stepper) Process(v Event) {
+	switch v := v.(type) {
+	case *UpdatePos:
+		c.pos = v.Pos
+	case *Tick:
+		c.pos.Handle(&MoveEntity{Delta: Offset{1, 0}})
+	}
+}
+
+// tracker is a Component which follows a ScentMap on each Tick.
+type tracker struct {
+	pos   *Tile
+	scent *ScentMap
+}
+
+// Process implements Component for tracker.
+func (c *tracker) Process(v Event) {
+	switch v := v.(type) {
+	case *UpdatePos:
+		c.pos = v.Pos
+	case *Tick:
+		if step := c.scent.Follow(c.pos); step != (Offset{}) {
+			c.pos.Handle(&MoveEntity{Delta: step})
+		}
+	}
+}
+
+func TestScentMapHunt(t *testing.T) {
+	var tiles []*Tile
+	StrGrid{
+		"##############",
+		"#............#",
+		"##############",
+	}.Convert(func(t *Tile, c byte) {
+		if c == '#' {
+			t.Pass = false
+		} else {
+			tiles = append(tiles, t)
+		}
+	})
+
+	// the prey leaves a trail which the hunter follows, with the prey, the
+	// ScentMap and the hunter all scheduled on the same DeltaClock
+	m := NewScentMap()
+	prey := &stepper{tiles[1]}
+	hunter := &tracker{tiles[0], m}
+	tiles[1].Occupant = &ComponentSlice{prey, &ScentTrail{m, 1}}
+	tiles[0].Occupant = &ComponentSlice{hunter}
+	tiles[1].Occupant.Handle(&UpdatePos{tiles[1]})
+
+	c := NewDeltaClock()
+	for _, e := range []Entity{tiles[1].Occupant, m, tiles[0].Occupant} {
+		c.Schedule(e, 1)
+	}
+	for i := 0; i < 20; i++ {
+		for _, e := range c.Tick() {
+			c.Schedule(e, 1)
+		}
+	}
+
+	if prey.pos != tiles[len(tiles)-1] {
+		t.Errorf("prey ended at %v, expected the end of the corridor", prey.pos.Offset)
+	}
+	if hunter.pos.Adjacent[Offset{1, 0}] != prey.pos {
+		t.Errorf("hunter ended at %v, expected to catch the prey at %v", hunter.pos.Offset, prey.pos.Offset)
+	}
+}
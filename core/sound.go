@@ -0,0 +1,73 @@
+package core
+
+// DampFn computes the loudness lost as a sound passes through a Tile.
+type DampFn func(*Tile) float64
+
+// SoundDamp is the default DampFn used by EmitSound. Sound loses 1 loudness
+// per translucent Tile, while non-translucent Tile such as walls absorb 4.
+func SoundDamp(t *Tile) float64 {
+	if t.Lite {
+		return 1
+	}
+	return 4
+}
+
+// Sound is an Event informing an Entity that it heard a sound. The Direction
+// is a step from the hearer towards where the sound appeared to come from,
+// which is not nessesarily a straight line towards the source. The Intensity
+// is the loudness remaining when the sound reached the hearer.
+type Sound struct {
+	Direction Offset
+	Intensity float64
+}
+
+// computeSound spreads a sound of the given loudness from the source Tile.
+// The resulting maps give the intensity of the sound at each Tile which heard
+// it, and the direction from which the sound arrived at that Tile.
+func computeSound(source *Tile, loudness float64, damp DampFn) (map[*Tile]float64, map[*Tile]Offset) {
+	// setup bookkeeping for a bounded breadth-first spread, similar to
+	// computeAttractWeights, except that since the damping varies by Tile, a
+	// Tile is revisited whenever a louder path to it is found.
+	intensity := map[*Tile]float64{source: loudness}
+	direction := map[*Tile]Offset{source: {}}
+	queue := []*Tile{source}
+
+	for len(queue) > 0 {
+		// pop the next Tile off the queue
+		curr := queue[0]
+		queue = queue[1:]
+
+		// expand the frontier using neighbors of curr
+		for step, adj := range curr.Adjacent {
+			level := intensity[curr] - damp(adj)
+			if level <= 0 {
+				continue
+			}
+			if prev, seen := intensity[adj]; !seen || level > prev {
+				intensity[adj] = level
+				direction[adj] = step.Neg()
+				queue = append(queue, adj)
+			}
+		}
+	}
+
+	return intensity, direction
+}
+
+// EmitSound creates a sound of the given loudness at the source Tile. The sound
+// spreads through the Tile graph, losing loudness according to the DampFn
+// (SoundDamp if nil), and each Occupant which hears the sound is sent a Sound
+// Event. The Occupant of the source Tile, which is presumably the one making
+// the sound, is not sent an Event.
+func EmitSound(source *Tile, loudness float64, damp DampFn) {
+	if damp == nil {
+		damp = SoundDamp
+	}
+
+	intensity, direction := computeSound(source, loudness, damp)
+	for tile, level := range intensity {
+		if tile != source && tile.Occupant != nil {
+			tile.Occupant.Handle(&Sound{direction[tile], level})
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"testing"
+)
+
+// soundRecorder is an Entity which records any Sound Event it receives.
+type soundRecorder struct {
+	heard []*Sound
+}
+
+// Handle implements Entity for soundRecorder.
+func (e *soundRecorder) Handle(v Event) {
+	if v, ok := v.(*Sound); ok {
+		e.heard = append(e.heard, v)
+	}
+}
+
+func SoundCase(g StrGrid) (source *Tile, hearers map[byte]*soundRecorder) {
+	hearers = make(map[byte]*soundRecorder)
+	callback := func(t *Tile, c byte) {
+		t.Lite = true
+		switch c {
+		case '#':
+			t.Pass = false
+			t.Lite = false
+		case '@':
+			source = t
+			t.Occupant = &soundRecorder{}
+		case '.':
+		default:
+			hearer := &soundRecorder{}
+			hearers[c] = hearer
+			t.Occupant = hearer
+		}
+	}
+	g.Convert(callback)
+	return source, hearers
+}
+
+func TestEmitSound(t *testing.T) {
+	source, hearers := SoundCase(StrGrid{
+		"###########",
+		"#@..a#b...#",
+		"#....#....#",
+		"#....#...c#",
+		"#.........#",
+		"###########",
+	})
+	EmitSound(source, 8, nil)
+
+	if heard := source.Occupant.(*soundRecorder).heard; len(heard) != 0 {
+		t.Errorf("EmitSound sent Sound to the source")
+	}
+
+	a := hearers['a'].heard
+	if len(a) != 1 || a[0].Intensity != 5 || a[0].Direction.X != -1 {
+		t.Errorf("EmitSound gave incorrect Sound to a: %v", a)
+	}
+
+	// b cannot hear through the wall, so the sound goes around the wall
+	b := hearers['b'].heard
+	if len(b) != 1 || b[0].Intensity != 1 || b[0].Direction.Y != 1 {
+		t.Errorf("EmitSound gave incorrect Sound to b: %v", b)
+	}
+
+	if c := hearers['c'].heard; len(c) != 0 {
+		t.Errorf("EmitSound sent Sound beyond its range: %v", c)
+	}
+}
+
+func TestEmitSoundDamp(t *testing.T) {
+	source, hearers := SoundCase(StrGrid{
+		"#########",
+		"#@......a",
+		"#########",
+	})
+	half := func(t *Tile) float64 {
+		if t.Pass {
+			return .5
+		}
+		return 4
+	}
+	EmitSound(source, 4, half)
+	a := hearers['a'].heard
+	if len(a) != 1 || a[0].Intensity != .5 || a[0].Direction != (Offset{-1, 0}) {
+		t.Errorf("EmitSound gave incorrect Sound with custom DampFn: %v", a)
+	}
+}
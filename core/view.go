@@ -37,8 +37,9 @@ func FoV(origin *Tile, radius int) map[Offset]*Tile {
 
 			// If the neighbor is translucient, push it onto the stack to
 			// continue exploration. Since we already added it to fov, when we
-			// pop it, we'll be able to access the position again.
-			if neighbor.Lite {
+			// pop it, we'll be able to access the position again. We cannot
+			// see past a Tile which is on a different Level than the origin.
+			if neighbor.Lite && neighbor.Level == origin.Level {
 				stack = append(stack, adj)
 			}
 		}
@@ -249,4 +250,87 @@ func computeReverseTable(radius int) map[Offset]Offset {
 	return reverse
 }
 
+// MergeFoV combines several fields of view, such as those computed by FoV,
+// into a single field of view whose offsets are relative to the given center
+// Tile. Each field of view is translated by the difference between the
+// Offset of its own origin (the Tile at Offset{0, 0}) and that of the center.
+// When fields disagree about an Offset, the earlier field of view takes
+// priority.
+func MergeFoV(center *Tile, fovs ...map[Offset]*Tile) map[Offset]*Tile {
+	merged := make(map[Offset]*Tile)
+	for _, fov := range fovs {
+		origin, ok := fov[Offset{0, 0}]
+		if !ok {
+			continue
+		}
+		shift := origin.Offset.Sub(center.Offset)
+		for off, tile := range fov {
+			if tile == nil {
+				continue
+			}
+			if _, seen := merged[off.Add(shift)]; !seen {
+				merged[off.Add(shift)] = tile
+			}
+		}
+	}
+	return merged
+}
+
+// InterceptFn gives the probability that a projectile passing through a Tile
+// is stopped by it, such as by cover or tall grass.
+type InterceptFn func(*Tile) float64
+
+// Projectile traces the flight of a projectile from the origin toward the goal
+// Tile. The line is computed using the same heuristic as Trace, but unlike
+// Trace, the Tile graph is walked so that the flight can be obstructed. Once
+// the goal is reached, the projectile continues along the same line until it
+// has travelled maxRange steps. If maxRange is less than the distance to the
+// goal, then the projectile falls short.
+//
+// The returned path contains each Tile traversed, excluding the origin. The
+// flight stops at the first Tile which has an Occupant, is non-translucent, or
+// which intercepts the projectile according to the given InterceptFn (which
+// may be nil). That Tile is the last Tile of the path and is returned as hit.
+// If the projectile runs out of range or off the edge of the map, hit is nil.
+// Interception is decided with the global Dice.
+func Projectile(origin, goal *Tile, maxRange int, intercept InterceptFn) (path []*Tile, hit *Tile) {
+	return ProjectileWith(globalDice, origin, goal, maxRange, intercept)
+}
+
+// ProjectileWith traces the flight of a projectile as with Projectile, but
+// decides interception with the given Dice.
+func ProjectileWith(d Dice, origin, goal *Tile, maxRange int, intercept InterceptFn) (path []*Tile, hit *Tile) {
+	// compute the steps needed to reach the goal - repeating these steps
+	// continues the line past the goal in the same direction.
+	line := Trace(goal.Offset.Sub(origin.Offset))
+	if len(line) == 0 {
+		return nil, nil
+	}
+	steps := make([]Offset, len(line))
+	prev := Offset{}
+	for i, o := range line {
+		steps[i] = o.Sub(prev)
+		prev = o
+	}
+
+	curr := origin
+	for i := 0; i < maxRange; i++ {
+		next, ok := curr.Adjacent[steps[i%len(steps)]]
+		if !ok {
+			return path, nil
+		}
+		path = append(path, next)
+
+		if next.Occupant != nil || !next.Lite {
+			return path, next
+		}
+		if intercept != nil && d.Chance(intercept(next)) {
+			return path, next
+		}
+		curr = next
+	}
+
+	return path, nil
+}
+
 // TODO Add circular version of FoV
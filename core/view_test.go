@@ -0,0 +1,184 @@
+package core
+
+import (
+	"testing"
+)
+
+// dummyEntity is an Entity which ignores every Event.
+type dummyEntity struct{}
+
+// Handle implements Entity for dummyEntity.
+func (dummyEntity) Handle(Event) {}
+
+func ProjectileCase(g StrGrid) (origin, goal, hit *Tile, path map[*Tile]struct{}) {
+	path = make(map[*Tile]struct{})
+	callback := func(t *Tile, c byte) {
+		t.Lite = true
+		switch c {
+		case '#':
+			t.Pass = false
+			t.Lite = false
+		case '@':
+			origin = t
+		case '$':
+			goal = t
+			path[t] = struct{}{}
+		case 'G':
+			goal = t
+		case 'x':
+			path[t] = struct{}{}
+		case 'X':
+			hit = t
+			t.Pass = false
+			t.Lite = false
+			path[t] = struct{}{}
+		case 'M':
+			hit = t
+			t.Occupant = dummyEntity{}
+			path[t] = struct{}{}
+		}
+	}
+	g.Convert(callback)
+	return origin, goal, hit, path
+}
+
+func TestProjectile(t *testing.T) {
+	cases := []struct {
+		g        StrGrid
+		maxRange int
+	}{
+		{
+			StrGrid{
+				"#######",
+				"#@xx$.#",
+				"#.....#",
+				"#######",
+			}, 3,
+		}, {
+			StrGrid{
+				"#######",
+				"#@xx$xX",
+				"#.....#",
+				"#######",
+			}, 10,
+		}, {
+			StrGrid{
+				"#######",
+				"#@x$xx#",
+				"#.....#",
+				"#######",
+			}, 4,
+		}, {
+			StrGrid{
+				"#######",
+				"#@....#",
+				"#.x...#",
+				"#..$..#",
+				"#...M.#",
+				"#######",
+			}, 10,
+		}, {
+			StrGrid{
+				"#######",
+				"#@....#",
+				"#.x...#",
+				"#..$..#",
+				"#...x.#",
+				"#####X#",
+			}, 10,
+		}, {
+			StrGrid{
+				"#######",
+				"#@xx.G#",
+				"#.....#",
+				"#######",
+			}, 2,
+		}, {
+			StrGrid{
+				"#######",
+				"#@xM.G#",
+				"#.....#",
+				"#######",
+			}, 10,
+		},
+	}
+	for i, c := range cases {
+		origin, goal, hit, expected := ProjectileCase(c.g)
+		path, actualHit := Projectile(origin, goal, c.maxRange, nil)
+		if !PathValid(append([]*Tile{origin}, path...)) {
+			t.Errorf("Projectile case %d gave invalid path", i)
+		}
+		if !PathsEqual(path, expected) {
+			t.Errorf("Projectile case %d gave incorrect path", i)
+		}
+		if actualHit != hit {
+			t.Errorf("Projectile case %d hit %v, expected %v", i, actualHit, hit)
+		}
+	}
+}
+
+func TestProjectileIntercept(t *testing.T) {
+	origin, goal, _, _ := ProjectileCase(StrGrid{
+		"#######",
+		"#@...$#",
+		"#######",
+	})
+	always := func(*Tile) float64 { return 1 }
+	path, hit := Projectile(origin, goal, 10, always)
+	if len(path) != 1 || hit != origin.Adjacent[Offset{1, 0}] {
+		t.Errorf("Projectile with certain intercept did not stop after one step")
+	}
+	never := func(*Tile) float64 { return 0 }
+	path, hit = Projectile(origin, goal, 4, never)
+	if len(path) != 4 || hit != nil || path[3] != goal {
+		t.Errorf("Projectile with no intercept did not reach goal")
+	}
+	sometimes := func(*Tile) float64 { return .5 }
+	path, _ = ProjectileWith(NewSeededDice(1234), origin, goal, 4, sometimes)
+	for i := 0; i < 10; i++ {
+		if again, _ := ProjectileWith(NewSeededDice(1234), origin, goal, 4, sometimes); len(again) != len(path) {
+			t.Errorf("ProjectileWith gave different flights for the same seed")
+		}
+	}
+}
+
+func TestMergeFoV(t *testing.T) {
+	var a, b *Tile
+	callback := func(t *Tile, c byte) {
+		t.Lite = true
+		switch c {
+		case '#':
+			t.Pass = false
+			t.Lite = false
+		case 'a':
+			a = t
+		case 'b':
+			b = t
+		}
+	}
+	StrGrid{
+		"###########",
+		"#a...#...b#",
+		"#....#....#",
+		"###########",
+	}.Convert(callback)
+
+	fovA, fovB := FoV(a, 3), FoV(b, 3)
+	merged := MergeFoV(a, fovA, fovB)
+	for _, fov := range []map[Offset]*Tile{fovA, fovB} {
+		for _, tile := range fov {
+			if tile == nil {
+				continue
+			}
+			if merged[tile.Offset.Sub(a.Offset)] != tile {
+				t.Errorf("MergeFoV misplaced %v", tile.Offset)
+			}
+		}
+	}
+	if len(merged) > len(fovA)+len(fovB) {
+		t.Errorf("MergeFoV contains extra Tile")
+	}
+	if merged[b.Offset.Sub(a.Offset)] != b {
+		t.Errorf("MergeFoV missing other origin")
+	}
+}
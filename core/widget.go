@@ -109,15 +109,18 @@ func (w *LogWidget) Update() {
 	}
 }
 
-// CameraWidget is a Widget which displays an Entity field of view
+// CameraWidget is a Widget which displays an Entity field of view. If the
+// Overlay is non-nil, each rendered Glyph is passed through it before being
+// drawn, which is useful for visualizing things like scent or Field weights.
 type CameraWidget struct {
 	Widget
-	Camera Entity
+	Camera  Entity
+	Overlay func(*Tile, Glyph) Glyph
 }
 
 // NewCameraWidget creates a new CameraWidget with the given camera Entity.
 func NewCameraWidget(camera Entity, x, y, w, h int) *CameraWidget {
-	return &CameraWidget{Widget{x, y, w, h}, camera}
+	return &CameraWidget{Widget{x, y, w, h}, camera, nil}
 }
 
 // Update draws the camera field of view on screen.
@@ -129,6 +132,9 @@ func (w *CameraWidget) Update() {
 	for offset, tile := range req.FoV {
 		req := RenderRequest{}
 		tile.Handle(&req)
+		if w.Overlay != nil {
+			req.Render = w.Overlay(tile, req.Render)
+		}
 		w.DrawRel(cx+offset.X, cy+offset.Y, req.Render)
 	}
 }
@@ -149,6 +155,30 @@ type FoVRequest struct {
 	FoV map[Offset]*Tile
 }
 
+// SharedFoV is an Entity which responds to FoVRequest with the combined field
+// of view of several Entity. The result is relative to the first Entity, so a
+// SharedFoV can be used as the Camera of a CameraWidget to show everything
+// seen by a group, such as the members of a tribe within earshot.
+type SharedFoV []Entity
+
+// Handle implements Entity for SharedFoV.
+func (e SharedFoV) Handle(v Event) {
+	if v, ok := v.(*FoVRequest); ok {
+		fovs := make([]map[Offset]*Tile, 0, len(e))
+		for _, member := range e {
+			req := FoVRequest{}
+			member.Handle(&req)
+			if req.FoV != nil {
+				fovs = append(fovs, req.FoV)
+			}
+		}
+		if len(fovs) == 0 {
+			return
+		}
+		v.FoV = MergeFoV(fovs[0][Offset{0, 0}], fovs...)
+	}
+}
+
 // PercentBarWidget displays a percent bar based on a bound percent function.
 type PercentBarWidget struct {
 	Widget
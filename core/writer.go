@@ -0,0 +1,258 @@
+package core
+
+import (
+	"strings"
+)
+
+// Cell identifies a cell written by a map generator, meaning the Offset and
+// Level of what would become a single Tile.
+type Cell struct {
+	Offset Offset
+	Level  int
+}
+
+// TileWriter receives the output of a map generator. Generators write the kind
+// of each cell, such as TileTypeWall, and then link neighboring cells so that
+// they are adjacent. This lets the same generator build a Tile graph for the
+// game, fill a dense grid, or render a text preview. Writing the same Cell more
+// than once replaces its kind, but keeps its links. Both Cells given to Link
+// must have been written, and the link goes in both directions.
+type TileWriter interface {
+	SetCell(c Cell, kind int)
+	Link(a, b Cell)
+}
+
+// multiWriter duplicates writes to several TileWriter.
+type multiWriter []TileWriter
+
+// MultiWriter creates a TileWriter which duplicates its writes to each of the
+// given TileWriter, so that a single run of a generator can be both played and
+// previewed.
+func MultiWriter(writers ...TileWriter) TileWriter {
+	return multiWriter(writers)
+}
+
+// SetCell implements TileWriter for multiWriter.
+func (m multiWriter) SetCell(c Cell, kind int) {
+	for _, w := range m {
+		w.SetCell(c, kind)
+	}
+}
+
+// Link implements TileWriter for multiWriter.
+func (m multiWriter) Link(a, b Cell) {
+	for _, w := range m {
+		w.Link(a, b)
+	}
+}
+
+// TileGraphWriter is a TileWriter which builds a graph of Tile, using a
+// MapGenInt to create the Tile for each cell.
+type TileGraphWriter struct {
+	gen   MapGenInt
+	cells map[Cell]*Tile
+	tiles []*Tile
+}
+
+// NewTileGraphWriter creates a TileGraphWriter which uses the given MapGenInt.
+func NewTileGraphWriter(f MapGenInt) *TileGraphWriter {
+	return &TileGraphWriter{gen: f, cells: make(map[Cell]*Tile)}
+}
+
+// SetCell implements TileWriter for TileGraphWriter. Rewriting a Cell creates a
+// new Tile with the MapGenInt, and copies it over the existing Tile, so that
+// any links to the Tile remain valid.
+func (w *TileGraphWriter) SetCell(c Cell, kind int) {
+	tile := w.gen(c.Offset, kind)
+	tile.Level = c.Level
+	if old, ok := w.cells[c]; ok {
+		tile.Adjacent = old.Adjacent
+		*old = *tile
+		return
+	}
+	w.cells[c] = tile
+	w.tiles = append(w.tiles, tile)
+}
+
+// Link implements TileWriter for TileGraphWriter.
+func (w *TileGraphWriter) Link(a, b Cell) {
+	from, to := w.cells[a], w.cells[b]
+	step := b.Offset.Sub(a.Offset)
+	from.Adjacent[step] = to
+	to.Adjacent[step.Neg()] = from
+}
+
+// Tiles returns each Tile which has been written, in the order in which their
+// Cells were first written. Generators which write a grid, such as
+// Heightmap.WriteCells, write their Cells in the same order as NewTileGrid.
+func (w *TileGraphWriter) Tiles() []*Tile {
+	return w.tiles
+}
+
+// GridWriter is a TileWriter which stores the kind of each cell in a dense
+// grid, which grows to fit the cells as they are written. Links are ignored,
+// since adjacency in a grid is implied. Where cells on different Levels share
+// an Offset, the cell on the lowest Level is kept, since higher Levels lie
+// deeper, so that the grid shows the map from above. However, a wall never
+// hides a cell which is not a wall. Kind 0 is used for cells which were never
+// written, so generators should not use 0 as a kind.
+type GridWriter struct {
+	origin   Offset
+	kinds    [][]int
+	levels   [][]int
+	min, max Offset
+}
+
+// NewGridWriter creates an empty GridWriter.
+func NewGridWriter() *GridWriter {
+	return &GridWriter{}
+}
+
+// newKindGrid creates a cols by rows grid of int, all of which are 0.
+func newKindGrid(cols, rows int) [][]int {
+	grid := make([][]int, cols)
+	for x := range grid {
+		grid[x] = make([]int, rows)
+	}
+	return grid
+}
+
+// grow ensures that the grid covers the given Offset.
+func (w *GridWriter) grow(o Offset) {
+	if w.kinds == nil {
+		w.origin, w.min, w.max = o, o, o
+		w.kinds, w.levels = newKindGrid(1, 1), newKindGrid(1, 1)
+		return
+	}
+
+	w.min = Offset{Min(w.min.X, o.X), Min(w.min.Y, o.Y)}
+	w.max = Offset{Max(w.max.X, o.X), Max(w.max.Y, o.Y)}
+	rel := o.Sub(w.origin)
+	if InBounds(rel.X, rel.Y, len(w.kinds), len(w.kinds[0])) {
+		return
+	}
+
+	// leave room to spare on every side, so that a map which grows steadily
+	// is not copied for every cell
+	spanX, spanY := w.max.X-w.min.X+1, w.max.Y-w.min.Y+1
+	origin := Offset{w.min.X - spanX/2, w.min.Y - spanY/2}
+	kinds, levels := newKindGrid(2*spanX, 2*spanY), newKindGrid(2*spanX, 2*spanY)
+	shift := w.origin.Sub(origin)
+	for x := range w.kinds {
+		copy(kinds[x+shift.X][shift.Y:], w.kinds[x])
+		copy(levels[x+shift.X][shift.Y:], w.levels[x])
+	}
+	w.origin, w.kinds, w.levels = origin, kinds, levels
+}
+
+// SetCell implements TileWriter for GridWriter.
+func (w *GridWriter) SetCell(c Cell, kind int) {
+	w.grow(c.Offset)
+	x, y := c.Offset.X-w.origin.X, c.Offset.Y-w.origin.Y
+
+	old, level := w.kinds[x][y], w.levels[x][y]
+	replace := old == 0 || c.Level == level
+	if !replace {
+		wall, oldWall := kind == TileTypeWall, old == TileTypeWall
+		replace = wall == oldWall && c.Level < level || oldWall && !wall
+	}
+	if replace {
+		w.kinds[x][y], w.levels[x][y] = kind, c.Level
+	}
+}
+
+// Link implements TileWriter for GridWriter, and does nothing.
+func (w *GridWriter) Link(a, b Cell) {}
+
+// Kind gets the kind of the cell at the given Offset, or 0 if no cell has been
+// written there.
+func (w *GridWriter) Kind(o Offset) int {
+	if w.kinds == nil {
+		return 0
+	}
+	rel := o.Sub(w.origin)
+	if !InBounds(rel.X, rel.Y, len(w.kinds), len(w.kinds[0])) {
+		return 0
+	}
+	return w.kinds[rel.X][rel.Y]
+}
+
+// Bounds returns the minimum and maximum Offsets of the written cells.
+func (w *GridWriter) Bounds() (min, max Offset) {
+	return w.min, w.max
+}
+
+// TileTypeLegend renders the TileType kinds in the same way as the default
+// MapGenBool, except that doors are drawn as '+'.
+var TileTypeLegend = map[int]rune{
+	TileTypeRoom:     '.',
+	TileTypeCorridor: '.',
+	TileTypeWall:     '#',
+	TileTypeDoor:     '+',
+}
+
+// TextWriter is a TileWriter which renders the cells written by a generator as
+// text, such as for previewing a generator or asserting on its output in a
+// test. The Legend gives the character for each kind, and any cell which was
+// never written, or whose kind is missing from the Legend, is drawn as a space.
+type TextWriter struct {
+	GridWriter
+	Legend map[int]rune
+}
+
+// NewTextWriter creates an empty TextWriter with the given Legend.
+func NewTextWriter(legend map[int]rune) *TextWriter {
+	return &TextWriter{Legend: legend}
+}
+
+// Lines returns one line of text for each row of the written cells, with the
+// first line and column being the minimum Offset given by Bounds.
+func (w *TextWriter) Lines() []string {
+	if w.kinds == nil {
+		return nil
+	}
+
+	var lines []string
+	for y := w.min.Y; y <= w.max.Y; y++ {
+		line := make([]rune, 0, w.max.X-w.min.X+1)
+		for x := w.min.X; x <= w.max.X; x++ {
+			ch, ok := w.Legend[w.Kind(Offset{x, y})]
+			if !ok {
+				ch = ' '
+			}
+			line = append(line, ch)
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// String implements fmt.Stringer for TextWriter, joining the Lines.
+func (w *TextWriter) String() string {
+	return strings.Join(w.Lines(), "\n")
+}
+
+// writeGrid writes a cols by rows grid of cells on Level 0 to a TileWriter,
+// with the first cell at the origin, and links each cell to its neighbors as
+// with NewTileGrid. The kind function is given the position of each cell
+// relative to the origin.
+func writeGrid(w TileWriter, cols, rows int, origin Offset, kind func(x, y int) int) {
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			w.SetCell(Cell{Offset: origin.Add(Offset{x, y})}, kind(x, y))
+		}
+	}
+
+	// each link goes both ways, so only link half of the neighbors
+	half := [4]Offset{{1, -1}, {1, 0}, {1, 1}, {0, 1}}
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			for _, step := range half {
+				if InBounds(x+step.X, y+step.Y, cols, rows) {
+					from := origin.Add(Offset{x, y})
+					w.Link(Cell{Offset: from}, Cell{Offset: from.Add(step)})
+				}
+			}
+		}
+	}
+}
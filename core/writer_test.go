@@ -0,0 +1,223 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWriteMazeText(t *testing.T) {
+	graph, text := NewTileGraphWriter(MapGenBool(defaultMapGenBool).kinds()), NewTextWriter(TileTypeLegend)
+	WriteHalfBraidMaze(MultiWriter(graph, text), 30, .5, 0, .5)
+
+	lines := text.Lines()
+	min, _ := text.Bounds()
+	cells := 0
+	for _, line := range lines {
+		for _, ch := range line {
+			if ch != ' ' {
+				cells++
+			}
+		}
+	}
+	if cells != len(graph.Tiles()) {
+		t.Errorf("TextWriter has %d cells, expected %d", cells, len(graph.Tiles()))
+	}
+
+	for _, tile := range graph.Tiles() {
+		o := tile.Offset.Sub(min)
+		expected := byte('.')
+		if !tile.Pass {
+			expected = '#'
+		}
+		if ch := lines[o.Y][o.X]; ch != expected {
+			t.Errorf("TextWriter gave %c at %v, expected %c", ch, tile.Offset, expected)
+		}
+		if tile.Pass && len(tile.Adjacent) != 8 {
+			t.Errorf("passable Tile at %v has %d neighbors", tile.Offset, len(tile.Adjacent))
+		}
+	}
+}
+
+func TestWriteDungeon(t *testing.T) {
+	gen := MapGenInt(func(o Offset, tiletype int) *Tile {
+		tile := NewTile(o)
+		tile.Pass = tiletype != TileTypeWall
+		return tile
+	})
+	graph, grid := NewTileGraphWriter(gen), NewGridWriter()
+	WriteDungeon(MultiWriter(graph, grid), 30, 4, 7)
+
+	tiles := graph.Tiles()
+	if NewRegions(tiles, Walking).Count() != 1 {
+		t.Errorf("WriteDungeon gave disconnected rooms")
+	}
+	doors := 0
+	for _, tile := range tiles {
+		kind := grid.Kind(tile.Offset)
+		if tile.Pass != (kind != TileTypeWall) {
+			t.Errorf("GridWriter gave kind %d at %v", kind, tile.Offset)
+		}
+		if kind == TileTypeDoor {
+			doors++
+		}
+		if tile.Pass && len(tile.Adjacent) != 8 {
+			t.Errorf("passable Tile at %v has %d neighbors", tile.Offset, len(tile.Adjacent))
+		}
+	}
+	if doors == 0 {
+		t.Errorf("WriteDungeon wrote no doors")
+	}
+}
+
+// passLinks copies the links between cells of a cellGraph which are not walls.
+func passLinks(g *cellGraph) map[Cell]map[Offset]Cell {
+	links := make(map[Cell]map[Offset]Cell)
+	for _, c := range g.order {
+		if g.kinds[c] == TileTypeWall {
+			continue
+		}
+		links[c] = make(map[Offset]Cell)
+		for step, adj := range g.links[c] {
+			if g.kinds[adj] != TileTypeWall {
+				links[c][step] = adj
+			}
+		}
+	}
+	return links
+}
+
+func TestAddWallsKeepsLayout(t *testing.T) {
+	graphs := []struct {
+		name  string
+		graph *cellGraph
+	}{
+		{"Dungeon", dungeonGraph(NewSeededDice(1234), 30, 4, 7)},
+		{"PerfectMaze", mazeGraph(abstractPerfect(NewSeededDice(1234), 60, .5, .3))},
+	}
+	for _, c := range graphs {
+		before := passLinks(c.graph)
+		c.graph.addWalls(TileTypeWall)
+		if after := passLinks(c.graph); !reflect.DeepEqual(before, after) {
+			t.Errorf("%s addWalls changed the links between passable cells", c.name)
+		}
+		for _, cell := range c.graph.order {
+			if c.graph.kinds[cell] == TileTypeWall {
+				continue
+			}
+			for _, step := range cardinal {
+				adj, ok := c.graph.links[cell][step]
+				if !ok {
+					adj = Cell{cell.Offset.Add(step), cell.Level}
+					if c.graph.kinds[adj] == TileTypeWall {
+						t.Errorf("%s addWalls left %v unlinked from a wall", c.name, cell)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWriteSeeded(t *testing.T) {
+	generators := []struct {
+		name  string
+		write func(w TileWriter, d Dice)
+	}{
+		{"WriteHalfBraidMazeWith", func(w TileWriter, d Dice) { WriteHalfBraidMazeWith(w, d, 60, .5, .3, .5) }},
+		{"WriteDungeonWith", func(w TileWriter, d Dice) { WriteDungeonWith(w, d, 30, 4, 7) }},
+		{"WriteCaveWith", func(w TileWriter, d Dice) { WriteCaveWith(w, d, 40, 30, DefaultCaveRule) }},
+	}
+	for _, g := range generators {
+		var texts [2]string
+		var offsets [2][]Offset
+		for i := range texts {
+			// draw from the global Dice in between, which must not matter
+			RandIntn(i + 1)
+			graph, text := NewTileGraphWriter(MapGenBool(defaultMapGenBool).kinds()), NewTextWriter(TileTypeLegend)
+			g.write(MultiWriter(graph, text), NewSeededDice(1234))
+			texts[i] = text.String()
+			for _, tile := range graph.Tiles() {
+				offsets[i] = append(offsets[i], tile.Offset)
+			}
+		}
+		if texts[0] != texts[1] {
+			t.Errorf("%s gave different maps for the same seed", g.name)
+		}
+		if !reflect.DeepEqual(offsets[0], offsets[1]) {
+			t.Errorf("%s gave Tiles in a different order for the same seed", g.name)
+		}
+	}
+}
+
+func TestGridWriterLevels(t *testing.T) {
+	w := NewTextWriter(TileTypeLegend)
+
+	// a lower Level hides a higher one, whichever is written first
+	w.SetCell(Cell{Offset{0, 0}, 0}, TileTypeRoom)
+	w.SetCell(Cell{Offset{0, 0}, 1}, TileTypeDoor)
+	w.SetCell(Cell{Offset{1, 0}, 1}, TileTypeRoom)
+	w.SetCell(Cell{Offset{1, 0}, 0}, TileTypeDoor)
+
+	// except that a wall never hides anything else
+	w.SetCell(Cell{Offset{2, 0}, 0}, TileTypeRoom)
+	w.SetCell(Cell{Offset{2, 0}, 1}, TileTypeWall)
+	w.SetCell(Cell{Offset{3, 0}, 1}, TileTypeWall)
+	w.SetCell(Cell{Offset{3, 0}, 0}, TileTypeDoor)
+
+	// rewriting a cell replaces it, and the grid grows to fit
+	w.SetCell(Cell{Offset{4, 0}, 0}, TileTypeWall)
+	w.SetCell(Cell{Offset{4, 0}, 0}, TileTypeDoor)
+	w.SetCell(Cell{Offset{-2, -1}, 0}, TileTypeWall)
+
+	expected := []string{
+		"#      ",
+		"  .+.++",
+	}
+	if lines := w.Lines(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("TextWriter gave %q, expected %q", lines, expected)
+	}
+	if w.Kind(Offset{10, 10}) != 0 || w.Kind(Offset{-1, 0}) != 0 {
+		t.Errorf("GridWriter gave kind for unwritten cell")
+	}
+}
+
+func TestHeightmapWriteCells(t *testing.T) {
+	h := NewHeightmap(4, 2)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			h.Write(x, y, float64(x+y)/4)
+		}
+	}
+	kind := func(height float64) int {
+		if height < .5 {
+			return TileTypeRoom
+		}
+		return TileTypeWall
+	}
+
+	graph, text := NewTileGraphWriter(func(o Offset, tiletype int) *Tile {
+		tile := NewTile(o)
+		tile.Pass = tiletype != TileTypeWall
+		return tile
+	}), NewTextWriter(TileTypeLegend)
+	h.WriteCells(MultiWriter(graph, text), kind)
+
+	expected := []string{"..##", ".###"}
+	if lines := text.Lines(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("WriteCells gave %q, expected %q", lines, expected)
+	}
+
+	// the Tiles should match those created by Apply
+	tiles := h.Apply(func(o Offset, height float64) *Tile {
+		tile := NewTile(o)
+		tile.Pass = height < .5
+		return tile
+	})
+	if !isTileGrid(graph.Tiles()) {
+		t.Errorf("WriteCells did not write in NewTileGrid order")
+	}
+	for i, tile := range graph.Tiles() {
+		if tile.Pass != tiles[i].Pass || len(tile.Adjacent) != len(tiles[i].Adjacent) {
+			t.Errorf("WriteCells gave different Tile at %v", tile.Offset)
+		}
+	}
+}
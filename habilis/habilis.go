@@ -28,10 +28,17 @@ func (e *Skin) Handle(v core.Event) {
 		key := core.GetKey()
 		if delta, ok := core.KeyMap[key]; ok {
 			e.Pos.Handle(&core.MoveEntity{Delta: delta})
+		} else if key == '>' {
+			e.Pos.Handle(&core.MoveEntity{Climb: 1})
+		} else if key == '<' {
+			e.Pos.Handle(&core.MoveEntity{Climb: -1})
 		} else if key == 't' {
 			if target, ok := core.Aim(e, e, "t"); ok {
 				e.Target = target
 			}
+		} else if key == 's' {
+			e.Logger.Log(core.Fmt("%s <shout>", e))
+			core.EmitSound(e.Pos, 8, nil)
 		} else if key == core.KeyEsc {
 			e.Expired = true
 		} else if key == 'T' {
@@ -47,6 +54,8 @@ func (e *Skin) Handle(v core.Event) {
 		e.Logger.Log(core.Fmt("%s <bump> %o", e, v.Bumped))
 	case *core.Collide:
 		e.Logger.Log(core.Fmt("%s <cannot> pass %o", e, v.Obstacle))
+	case *core.Sound:
+		e.Logger.Log(core.Fmt("%s <hear> a noise", e))
 	case *core.FoVRequest:
 		v.FoV = core.FoV(e.Pos, 5)
 	case *core.Mark:
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"time"
+
 	"github.com/rauko1753/stones/core"
 	"github.com/rauko1753/stones/habilis"
 )
@@ -17,7 +20,7 @@ var boolgen = core.MapGenBool(func(o core.Offset, pass bool) *core.Tile {
 	return t
 })
 
-var biomes = core.BiomeList{
+var seas = core.BiomeList{
 	core.Biome{
 		Boundary:   .3,
 		PassChance: 0,
@@ -36,30 +39,44 @@ var biomes = core.BiomeList{
 		},
 		ImpassLite: true,
 	},
-	core.Biome{
-		Boundary:   1,
-		PassChance: .95,
-		PassTiles: []core.Glyph{
-			{Ch: '.', Fg: core.ColorGreen},
-		},
-		ImpassTiles: []core.Glyph{
-			{Ch: '%', Fg: core.ColorGreen},
-		},
-	},
 }
 
-func genMaze() []*core.Tile {
+var river = core.MapGenFloat(func(o core.Offset, height float64) *core.Tile {
+	t := core.NewTile(o)
+	t.Face = core.Glyph{'~', core.ColorLightCyan}
+	return t
+})
+
+var lake = core.MapGenFloat(func(o core.Offset, height float64) *core.Tile {
+	t := core.NewTile(o)
+	t.Face = core.Glyph{'~', core.ColorLightBlue}
+	t.Pass = false
+	return t
+})
+
+func genMaze(dice core.Dice) []*core.Tile {
 	numNodes := 10
 	runProb := .5
 	weaveProb := 0.
 	loopProb := .5
-	return boolgen.HalfBraidMaze(numNodes, runProb, weaveProb, loopProb)
+	return boolgen.HalfBraidMazeWith(dice, numNodes, runProb, weaveProb, loopProb)
 }
 
-func genOverworld() *core.Tile {
+func genOverworld(dice core.Dice) []*core.Tile {
 	h := core.NewHeightmap(200, 400)
-	h.Generate()
-	overworld := biomes.NewMapGen().Overworld(h)
+	h.GenerateWith(dice)
+
+	temp, moist := core.NewTemperatureMap(h), core.NewMoistureMap(h)
+	temp.GenerateWith(dice)
+	moist.Generate()
+	water := core.NewHydrology(h)
+	water.Moisture = moist
+	water.Generate()
+
+	biomes := core.WhittakerBiomes
+	biomes.SeaLevel, biomes.Sea = .4, seas
+	gen := water.NewMapGen(biomes.NewMapGenWith(dice, temp, moist), river, lake)
+	overworld := gen.Overworld(h)
 
 	for _, tile := range overworld {
 		if len(tile.Adjacent) < 8 {
@@ -69,36 +86,102 @@ func genOverworld() *core.Tile {
 		}
 	}
 
-	return core.RandPassTile(overworld)
+	return overworld
 }
 
-func genDungeon() *core.Tile {
-	var gen = core.MapGenInt(func(o core.Offset, tiletype int) *core.Tile {
-		tile := core.NewTile(o)
-		switch tiletype {
-		case core.TileTypeRoom:
-			tile.Face = core.Glyph{'.', core.ColorLightWhite}
-		case core.TileTypeCorridor:
-			tile.Face = core.Glyph{'.', core.ColorLightBlack}
-		case core.TileTypeDoor:
-			tile.Face = core.Glyph{'+', core.ColorWhite}
-			tile.Lite = false
-		case core.TileTypeWall:
-			tile.Face = core.Glyph{'#', core.ColorWhite}
-			tile.Pass = false
-			tile.Lite = false
+var dungeongen = core.MapGenInt(func(o core.Offset, tiletype int) *core.Tile {
+	tile := core.NewTile(o)
+	switch tiletype {
+	case core.TileTypeRoom:
+		tile.Face = core.Glyph{'.', core.ColorLightWhite}
+	case core.TileTypeCorridor:
+		tile.Face = core.Glyph{'.', core.ColorLightBlack}
+	case core.TileTypeDoor:
+		tile.Face = core.Glyph{'+', core.ColorWhite}
+		tile.Lite = false
+	case core.TileTypeWall:
+		tile.Face = core.Glyph{'#', core.ColorWhite}
+		tile.Pass = false
+		tile.Lite = false
+	}
+	return tile
+})
+
+var shrine = core.MustPrefab([]string{
+	"#####",
+	"#._.#",
+	"#...#",
+	"##+##",
+}, map[byte]int{
+	'#': core.TileTypeWall,
+	'.': core.TileTypeRoom,
+	'+': core.TileTypeDoor,
+	'_': core.TileTypeRoom,
+})
+
+func genDungeon(dice core.Dice) []*core.Tile {
+	dungeon := core.DungeonWith(dice, 50, 6, 10, dungeongen)
+	if marks, err := shrine.RandOrientWith(dice).PlaceWith(dice, dungeon, core.Clearing, dungeongen); err == nil {
+		for _, altar := range marks['_'] {
+			altar.Face = core.Glyph{'_', core.ColorLightYellow}
 		}
-		return tile
-	})
-	tiles := core.Dungeon(50, 6, 10, gen)
-	return core.RandPassTile(tiles)
+	}
+	return dungeon
+}
+
+// genWorld generates every map from a single Dice, so that the same seed always
+// gives the same world.
+func genWorld(dice core.Dice) *core.Tile {
+	world := genOverworld(dice)
+
+	// dig the maze into a hillside, meaning a site with some brush but no water
+	maze := genMaze(dice)
+	min, max := core.TileBounds(maze)
+	hillside := func(footprint []*core.Tile) bool {
+		brush := 0
+		for _, tile := range footprint {
+			if tile.Face.Ch == '~' || tile.Face.Ch == '#' {
+				return false
+			} else if !tile.Pass {
+				brush++
+			}
+		}
+		return brush > len(footprint)/20
+	}
+	if site, ok := core.FindSiteWith(dice, world, max.X-min.X+1, max.Y-min.Y+1, hillside); ok {
+		opened := 0
+		entrance := func(*core.Tile) bool {
+			opened++
+			return opened <= 2
+		}
+		door := func(o core.Offset) *core.Tile {
+			return boolgen(o, true)
+		}
+		if combined, err := core.Embed(world, maze, site, entrance, door); err == nil {
+			world = combined
+		}
+	}
+
+	// put the dungeon beneath the overworld, reached by stairs
+	origin := dice.PassTile(world)
+	dungeon := genDungeon(dice)
+	entrance := dice.PassTile(dungeon)
+	if err := core.EmbedBelow(origin, dungeon, entrance); err == nil {
+		origin.Face = core.Glyph{'>', core.ColorWhite}
+		entrance.Face = core.Glyph{'<', core.ColorWhite}
+	}
+
+	return origin
 }
 
 func main() {
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed for generating the world")
+	flag.Parse()
+
 	core.MustTermInit()
 	defer core.TermDone()
 
-	origin := genDungeon()
+	origin := genWorld(core.NewSeededDice(*seed))
 
 	hero := habilis.Skin{
 		Name: "you",
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/rauko1753/stones/core"
+)
+
+// reachable gives every Tile reachable from the origin through Adjacent and
+// Stairs links, in breadth-first order with the links of each Tile visited in
+// a fixed order, so that the same world always gives the same sequence.
+func reachable(origin *core.Tile) []*core.Tile {
+	tiles := []*core.Tile{origin}
+	seen := map[*core.Tile]struct{}{origin: {}}
+	visit := func(t *core.Tile) {
+		if _, ok := seen[t]; !ok {
+			seen[t] = struct{}{}
+			tiles = append(tiles, t)
+		}
+	}
+
+	for head := 0; head < len(tiles); head++ {
+		curr := tiles[head]
+		for _, step := range sortedSteps(curr) {
+			visit(curr.Adjacent[step])
+		}
+		for _, climb := range sortedClimbs(curr) {
+			visit(curr.Stairs[climb])
+		}
+	}
+	return tiles
+}
+
+// sortedSteps gives the keys of the Adjacent map of a Tile in a fixed order.
+func sortedSteps(t *core.Tile) []core.Offset {
+	steps := make([]core.Offset, 0, len(t.Adjacent))
+	for step := range t.Adjacent {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		if steps[i].X != steps[j].X {
+			return steps[i].X < steps[j].X
+		}
+		return steps[i].Y < steps[j].Y
+	})
+	return steps
+}
+
+// sortedClimbs gives the keys of the Stairs map of a Tile in a fixed order.
+func sortedClimbs(t *core.Tile) []int {
+	climbs := make([]int, 0, len(t.Stairs))
+	for climb := range t.Stairs {
+		climbs = append(climbs, climb)
+	}
+	sort.Ints(climbs)
+	return climbs
+}
+
+func TestGenWorldSeeded(t *testing.T) {
+	var worlds [2][]*core.Tile
+	for i := range worlds {
+		// draw from the global Dice in between, which must not matter
+		core.RandIntn(i + 1)
+		worlds[i] = reachable(genWorld(core.NewSeededDice(1234)))
+	}
+
+	a, b := worlds[0], worlds[1]
+	if len(a) != len(b) {
+		t.Fatalf("seeded worlds reach %d and %d Tiles", len(a), len(b))
+	}
+	levels := make(map[int]struct{})
+	for i := range a {
+		ta, tb := a[i], b[i]
+		if ta.Face != tb.Face || ta.Pass != tb.Pass || ta.Lite != tb.Lite || ta.Offset != tb.Offset || ta.Level != tb.Level {
+			t.Fatalf("seeded worlds differ at Tile %d: %v and %v", i, ta.Offset, tb.Offset)
+		}
+		if len(ta.Adjacent) != len(tb.Adjacent) || len(ta.Stairs) != len(tb.Stairs) {
+			t.Fatalf("seeded worlds have different links at %v", ta.Offset)
+		}
+		levels[ta.Level] = struct{}{}
+	}
+	if len(levels) != 2 {
+		t.Errorf("seeded world reached %d Levels, expected 2", len(levels))
+	}
+}